@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+// fileVar is replaced by the input name in --prefix and --suffix.
+const fileVar = "{file}"
+
+// lineAffix wraps output lines between a literal prefix and suffix.
+type lineAffix struct {
+	prefix string
+	suffix string
+}
+
+func newLineAffix(prefix, suffix string) *lineAffix {
+	return &lineAffix{prefix: prefix, suffix: suffix}
+}
+
+// wrap returns each line of formatted, ended by newline, between the prefix and suffix
+// of input name. Multi-line output (e.g. pretty output) is wrapped line by line.
+func (a *lineAffix) wrap(name string, formatted []byte) []byte {
+	prefix := strings.Replace(a.prefix, fileVar, name, -1)
+	suffix := strings.Replace(a.suffix, fileVar, name, -1)
+	lines := bytes.SplitAfter(formatted, []byte("\n"))
+	out := make([]byte, 0, len(formatted)+len(lines)*(len(prefix)+len(suffix)))
+	for _, line := range lines {
+		if len(line) == 0 { // After the last newline
+			continue
+		}
+		body := bytes.TrimSuffix(line, []byte("\n"))
+		out = append(out, prefix...)
+		out = append(out, body...)
+		out = append(out, suffix...)
+		if len(body) < len(line) {
+			out = append(out, '\n')
+		}
+	}
+	return out
+}
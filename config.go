@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// loadConfig sets flags not set on command line from JSON config file at path.
+// Config keys are flag names (e.g. {"f": "time,level,msg", "colors": "cyan,green"}),
+// values of repeatable flags (e.g. match) can be arrays.
+// Named profiles of flags can be defined under "profiles" key, values of the
+// profile selected by name override the top-level ones.
+func loadConfig(path, profile string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	profiles, _ := config["profiles"].(map[string]interface{})
+	delete(config, "profiles")
+	if profile != "" {
+		values, ok := profiles[profile].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("profile %q not found in %s", profile, path)
+		}
+		for name, val := range values {
+			config[name] = val
+		}
+	}
+
+	// Command line flags take priority over config values
+	cmdFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		cmdFlags[f.Name] = true
+	})
+
+	// Apply in stable order so errors are reproducible
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "config" || name == "profile" || flag.Lookup(name) == nil {
+			return fmt.Errorf("unknown flag %q in %s", name, path)
+		}
+		if cmdFlags[name] {
+			continue
+		}
+
+		values, ok := config[name].([]interface{})
+		if !ok {
+			values = []interface{}{config[name]}
+		}
+		for _, val := range values {
+			if err := flag.Set(name, configValue(val)); err != nil {
+				return fmt.Errorf("invalid value of %q in %s: %v", name, path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// loadEnv sets flags not set on command line from NICE_<FLAG> environment variables
+// looked up by lookup, e.g. NICE_TIME_FORMAT for --time-format. -f is set by NICE_FIELDS.
+// Environment variables take priority over config values.
+func loadEnv(lookup func(string) (string, bool)) error {
+	cmdFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		cmdFlags[f.Name] = true
+	})
+
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		if err != nil || cmdFlags[f.Name] {
+			return
+		}
+		name := "NICE_" + strings.ToUpper(strings.Replace(f.Name, "-", "_", -1))
+		if f.Name == "f" {
+			name = "NICE_FIELDS"
+		}
+		val, ok := lookup(name)
+		if !ok {
+			return
+		}
+		if setErr := flag.Set(f.Name, val); setErr != nil {
+			err = fmt.Errorf("invalid value of %s: %v", name, setErr)
+		}
+	})
+	return err
+}
+
+// configValue returns the flag string of a JSON config value.
+func configValue(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case float64:
+		// Avoid exponent format of large numbers (e.g. max-line)
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// readFieldsFile reads output fields from file at path, separated by newline or comma (,).
+// Lines starting with # are comments.
+func readFieldsFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var fields []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, field := range strings.Split(line, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return strings.Join(fields, ","), nil
+}
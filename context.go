@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/lnquy/nice/pkg/nice"
+	"github.com/tidwall/gjson"
+)
+
+// contextSeparator separates non-adjacent groups of context lines, like grep.
+var contextSeparator = []byte("--\n")
+
+// contextLines prints lines around the lines passed the filters of an input, like grep -C.
+// Overlapping contexts are merged, so each line is printed once.
+type contextLines struct {
+	size   int
+	before []contextLine // Last filtered lines not printed yet, oldest first
+	after  int           // Number of filtered lines left to print after the last passed line
+	buff   *bytes.Buffer
+
+	printed bool // Any line printed
+	dropped bool // Filtered lines dropped since the last printed line
+}
+
+type contextLine struct {
+	srcLine uint64
+	line    []byte
+}
+
+func newContextLines(size int) *contextLines {
+	return &contextLines{size: size, buff: bytes.NewBuffer(make([]byte, 0, 1024))}
+}
+
+// print prints line at srcLine of input src, preceded by the context lines before it if it
+// passes the filters. Filtered lines are printed only as context.
+func (c *contextLines) print(p *printer, src *lineStats, srcLine uint64, line []byte, buff *bytes.Buffer, out io.Writer) nice.Result {
+	emit := p.emitFunc(src.name, srcLine, out)
+	res := p.format(src, line, buff, func(formatted []byte, jsonLine gjson.Result) bool {
+		c.flushBefore(p, src.name, out)
+		return emit(formatted, jsonLine)
+	})
+
+	switch res {
+	case nice.Printed, nice.Passthrough:
+		c.after = c.size
+	case nice.Filtered:
+		if c.after > 0 {
+			c.after--
+			c.buff.Reset()
+			p.f.FormatContext(line, c.buff, emit)
+			return res
+		}
+		if len(c.before) == c.size {
+			c.before = c.before[1:]
+			c.dropped = true
+		}
+		c.before = append(c.before, contextLine{srcLine: srcLine, line: append([]byte(nil), line...)})
+	}
+	return res
+}
+
+// flushBefore prints the context lines before a passed line, separated from the previous
+// printed lines if some lines between them are dropped.
+func (c *contextLines) flushBefore(p *printer, name string, out io.Writer) {
+	if c.printed && c.dropped {
+		p.writeUncounted(contextSeparator, out)
+	}
+	c.printed, c.dropped = true, false
+	for _, before := range c.before {
+		c.buff.Reset()
+		p.f.FormatContext(before.line, c.buff, p.emitFunc(name, before.srcLine, out))
+	}
+	c.before = c.before[:0]
+}
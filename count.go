@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+)
+
+// valueCounter tallies distinct values of a field across all inputs.
+type valueCounter struct {
+	field string
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// len returns the number of distinct values counted.
+func (c *valueCounter) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.counts)
+}
+
+func newValueCounter(field string) *valueCounter {
+	return &valueCounter{
+		field:  field,
+		counts: make(map[string]int),
+	}
+}
+
+func (c *valueCounter) add(value string) {
+	c.mu.Lock()
+	c.counts[value]++
+	c.mu.Unlock()
+}
+
+// print writes the values and their counts to w, sorted by count from highest.
+// Values having the same count are sorted alphabetically.
+func (c *valueCounter) print(w io.Writer) error {
+	c.mu.Lock()
+	values := make([]string, 0, len(c.counts))
+	for val := range c.counts {
+		values = append(values, val)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		ci, cj := c.counts[values[i]], c.counts[values[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return values[i] < values[j]
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tcount\n", c.field)
+	for _, val := range values {
+		fmt.Fprintf(tw, "%s\t%d\n", val, c.counts[val])
+	}
+	c.mu.Unlock()
+	return tw.Flush()
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Input formats converted to JSON lines before formatting
+const (
+	inputCSV = "csv"
+	inputTSV = "tsv"
+)
+
+// csvRows converts CSV or TSV rows of an input to JSON objects keyed by the column names
+// of its header row, so fields can be selected by column name.
+// Quoted values may contain separators, doubled quotes and newlines, spanning multiple lines.
+// Rows shorter than the header have no fields for the missing columns, values beyond
+// the header or under an empty column name are keyed by their column number (e.g. col5).
+type csvRows struct {
+	comma   rune
+	name    string // Input name to log errors
+	maxSize int    // Records are dropped once longer than this
+	fn      func(line []byte)
+
+	header []string
+	record []byte // Pending lines of a record with an open quoted value
+}
+
+func newCSVRows(comma rune, name string, maxSize int, fn func(line []byte)) *csvRows {
+	return &csvRows{comma: comma, name: name, maxSize: maxSize, fn: fn}
+}
+
+// add adds line to the pending record and calls fn with the JSON object of the record once it's complete.
+func (r *csvRows) add(line []byte) {
+	if len(r.record) > 0 {
+		r.record = append(r.record, '\n')
+	}
+	r.record = append(r.record, line...)
+	if quoteOpen(r.record, byte(r.comma)) { // Quoted value continues on the next line
+		if len(r.record) <= r.maxSize {
+			return
+		}
+		log.Printf("nice: [%v]: CSV record longer than %d bytes. Dropped", r.name, r.maxSize)
+		r.record = r.record[:0]
+		return
+	}
+
+	reader := csv.NewReader(bytes.NewReader(r.record))
+	reader.Comma = r.comma
+	reader.FieldsPerRecord = -1 // Ragged rows are allowed
+	reader.LazyQuotes = true
+	values, err := reader.Read()
+	r.record = r.record[:0]
+	if err != nil {
+		if len(bytes.TrimSpace(line)) > 0 {
+			log.Printf("nice: [%v]: invalid CSV record: %v", r.name, err)
+		}
+		return
+	}
+	if r.header == nil {
+		values[0] = strings.TrimPrefix(values[0], "\ufeff") // UTF-8 BOM
+		r.header = values
+		return
+	}
+	r.fn(r.toJSON(values))
+}
+
+// quoteOpen reports whether record ends inside a quoted value, so the record continues on the next line.
+// Only values starting with a quote are quoted, quotes inside unquoted values (e.g. 5" screen) are literal.
+// A quoted value is closed by the first quote which is not doubled, even if it's not followed by comma,
+// so a stray quote cannot swallow the following rows.
+func quoteOpen(record []byte, comma byte) bool {
+	quoted, fieldStart := false, true
+	for i := 0; i < len(record); i++ {
+		c := record[i]
+		switch {
+		case quoted:
+			if c != '"' {
+				continue
+			}
+			if i+1 < len(record) && record[i+1] == '"' { // Escaped quote
+				i++
+				continue
+			}
+			quoted = false
+		case fieldStart && c == '"':
+			quoted, fieldStart = true, false
+		default:
+			fieldStart = c == comma || c == '\n'
+		}
+	}
+	return quoted
+}
+
+func (r *csvRows) toJSON(values []string) []byte {
+	buff := bytes.NewBuffer(make([]byte, 0, 256))
+	buff.WriteByte('{')
+	for idx, val := range values {
+		name := ""
+		if idx < len(r.header) {
+			name = r.header[idx]
+		}
+		if name == "" {
+			name = "col" + strconv.Itoa(idx+1)
+		}
+		if idx > 0 {
+			buff.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		v, _ := json.Marshal(val)
+		buff.Write(key)
+		buff.WriteByte(':')
+		buff.Write(v)
+	}
+	buff.WriteByte('}')
+	return buff.Bytes()
+}
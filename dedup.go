@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tidwall/gjson"
+)
+
+// deduper collapses consecutive output lines having the same key into one.
+// It's shared by all inputs, so lines are consecutive in output order.
+type deduper struct {
+	fields    []string // Fields to build the key from, formatted line is the key if empty
+	showCount bool     // Append (xN) repeat count, the last line is held until its repeats end
+
+	mu      sync.Mutex
+	key     string
+	count   int
+	pending []byte
+}
+
+// newDeduper returns a deduper keyed by fields (separated by comma) or by the formatted line if empty.
+func newDeduper(fields string, showCount bool) *deduper {
+	d := &deduper{showCount: showCount}
+	for _, f := range strings.Split(fields, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			d.fields = append(d.fields, f)
+		}
+	}
+	return d
+}
+
+// lineKey returns the dedup key of a formatted line. Lines not parsed (e.g. passthrough)
+// are keyed by the line itself.
+func (d *deduper) lineKey(jsonLine gjson.Result, line []byte) string {
+	if len(d.fields) == 0 || !jsonLine.Exists() {
+		return string(line)
+	}
+	var sb strings.Builder
+	for _, f := range d.fields {
+		sb.WriteString(jsonLine.Get(f).Raw)
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+// write writes line by fn unless it's a repeat of the last line.
+// It reports whether line was dropped as a repeat.
+func (d *deduper) write(key string, line []byte, fn func([]byte)) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.count > 0 && key == d.key {
+		d.count++
+		return true
+	}
+
+	d.flushLocked(fn)
+	d.key = key
+	d.count = 1
+	if d.showCount {
+		d.pending = append(d.pending[:0], line...)
+	} else {
+		fn(line)
+	}
+	return false
+}
+
+// flush writes the held line, if any, by fn.
+func (d *deduper) flush(fn func([]byte)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.flushLocked(fn)
+}
+
+func (d *deduper) flushLocked(fn func([]byte)) {
+	if !d.showCount || d.count == 0 {
+		return
+	}
+	line := d.pending
+	if d.count > 1 {
+		// Insert the count before the trailing newline
+		line = append(line[:len(line)-1:len(line)-1], " (x"+strconv.Itoa(d.count)+")\n"...)
+	}
+	fn(line)
+	d.count = 0
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// followPollInterval is the delay between checks for new data once EOF reached.
+const followPollInterval = 250 * time.Millisecond
+
+// followReader wraps a file and blocks on EOF waiting for appended data,
+// like tail -f. When the file at path is rotated (different inode) or
+// truncated, it is reopened and read from the beginning.
+type followReader struct {
+	ctx  context.Context
+	path string
+	f    *os.File
+
+	onReset func() // Called when reading restarts from the beginning, if set
+}
+
+func newFollowReader(ctx context.Context, path string, f *os.File) *followReader {
+	return &followReader{
+		ctx:  ctx,
+		path: path,
+		f:    f,
+	}
+}
+
+func (r *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.f.Read(p)
+		if n > 0 || (err != nil && err != io.EOF) {
+			return n, err
+		}
+
+		// EOF: wait for new data or cancellation
+		select {
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		case <-time.After(followPollInterval):
+		}
+		r.checkRotation()
+	}
+}
+
+// checkRotation reopens the path if it now points to another file,
+// or rewinds the current file if it has been truncated.
+func (r *followReader) checkRotation() {
+	pathInfo, err := os.Stat(r.path)
+	if err != nil {
+		// File may be removed temporarily while rotating, keep the old one
+		return
+	}
+	curInfo, err := r.f.Stat()
+	if err != nil {
+		return
+	}
+
+	if !os.SameFile(curInfo, pathInfo) {
+		f, err := os.OpenFile(r.path, os.O_RDONLY, 0400)
+		if err != nil {
+			log.Printf("nice: [%v]: failed to reopen rotated file: %v", r.path, err)
+			return
+		}
+		logInfof("nice: [%v]: file rotated. Reopened", r.path)
+		if err := r.f.Close(); err != nil {
+			log.Printf("nice: failed to close file %v: %v", r.path, err)
+		}
+		r.f = f
+		if r.onReset != nil {
+			r.onReset()
+		}
+		return
+	}
+
+	offset, err := r.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	if pathInfo.Size() < offset {
+		logInfof("nice: [%v]: file truncated. Read from beginning", r.path)
+		if _, err := r.f.Seek(0, io.SeekStart); err != nil {
+			log.Printf("nice: [%v]: failed to seek truncated file: %v", r.path, err)
+		} else if r.onReset != nil {
+			r.onReset()
+		}
+	}
+}
+
+func (r *followReader) Close() error {
+	return r.f.Close()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/lnquy/nice/pkg/nice"
+	"github.com/tidwall/gjson"
+)
+
+// grouper separates output lines into groups by the value of a field, printing
+// a header line each time the value changes. Lines without the field stay in the current group.
+// It's shared by all inputs, so groups follow the output order.
+type grouper struct {
+	field string
+
+	mu      sync.Mutex
+	started bool
+	value   string
+}
+
+func newGrouper(field string) *grouper {
+	return &grouper{field: field}
+}
+
+// write calls fn to write line, preceded by the header of its group by writeHeader if the
+// group value changed from the previous line.
+func (g *grouper) write(jsonLine gjson.Result, writeHeader func([]byte), fn func() bool) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if jsField := jsonLine.Get(g.field); jsField.Exists() {
+		if val := nice.FieldValue(jsField); !g.started || val != g.value {
+			writeHeader(g.header(val))
+			g.started, g.value = true, val
+		}
+	}
+	return fn()
+}
+
+// header returns the group header line, separated from the previous group by a blank line.
+func (g *grouper) header(val string) []byte {
+	header := color.New(color.Bold).Sprintf("── %s: %s ──", g.field, val) + "\n"
+	if g.started {
+		header = "\n" + header
+	}
+	return []byte(header)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// httpRetryInterval is the delay before reconnecting to an HTTP endpoint in follow mode.
+const httpRetryInterval = time.Second
+
+// isHTTPURL reports whether the input entry is a http:// or https:// URL.
+func isHTTPURL(entry string) bool {
+	return strings.HasPrefix(entry, "http://") || strings.HasPrefix(entry, "https://")
+}
+
+// scanHTTP streams the response body of GET url and calls fn on each line,
+// or on the data of each event if --sse is set.
+// In follow mode, it reconnects when the request failed or the stream ended, until ctx cancelled.
+func scanHTTP(ctx context.Context, url string, fn func(line []byte)) {
+	var sse *sseReader
+	if fSSE {
+		sse = &sseReader{fn: fn}
+		fn = sse.line
+	}
+	for {
+		if err := readHTTP(ctx, url, sse, fn); err != nil && ctx.Err() == nil {
+			log.Printf("nice: [%v]: %v", url, err)
+		}
+		if sse != nil {
+			sse.dispatch() // Stream may end without the blank line of the last event
+		}
+
+		if !fFollow || ctx.Err() != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(httpRetryInterval):
+			logInfof("nice: [%v]: reconnecting", url)
+		}
+	}
+}
+
+func readHTTP(ctx context.Context, url string, sse *sseReader, fn func(line []byte)) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("invalid request: %v", err)
+	}
+	req = req.WithContext(ctx) // Cancel the request and pending body reads when ctx cancelled
+	if sse != nil {
+		req.Header.Set("Accept", "text/event-stream")
+		if sse.lastID != "" {
+			req.Header.Set("Last-Event-ID", sse.lastID)
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil && ctx.Err() == nil {
+			log.Printf("nice: [%v]: failed to close response body: %v", url, err)
+		}
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	logInfof("nice: [%v]: connected", url)
+	scanLines(ctx, url, resp.Body, fn)
+	return nil
+}
+
+// sseReader parses Server-Sent Events stream lines and calls fn with the data of each event.
+// Data of multiple data: lines in one event are joined by newline.
+type sseReader struct {
+	fn      func(line []byte)
+	data    bytes.Buffer
+	hasData bool
+	lastID  string // Sent on reconnect so the server can resume the stream
+}
+
+func (r *sseReader) line(line []byte) {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if len(line) == 0 {
+		r.dispatch()
+		return
+	}
+	if line[0] == ':' { // Comment, e.g. keep-alive
+		return
+	}
+	name, val := line, []byte(nil)
+	if idx := bytes.IndexByte(line, ':'); idx >= 0 {
+		name, val = line[:idx], bytes.TrimPrefix(line[idx+1:], []byte(" "))
+	}
+	switch string(name) {
+	case "data":
+		if r.hasData {
+			r.data.WriteByte('\n')
+		}
+		r.data.Write(val)
+		r.hasData = true
+	case "id":
+		r.lastID = string(val)
+	}
+}
+
+// dispatch calls fn with the data of the pending event, if any.
+func (r *sseReader) dispatch() {
+	if !r.hasData {
+		return
+	}
+	r.fn(r.data.Bytes())
+	r.data.Reset()
+	r.hasData = false
+}
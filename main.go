@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"flag"
 	"fmt"
@@ -10,195 +11,1245 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"regexp"
+	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/lnquy/nice/pkg/nice"
 	"github.com/tidwall/gjson"
 )
 
 var (
-	fInputFiles   string
-	fOutputFormat string
-	fFieldColors  string
+	fConfig         string
+	fProfile        string
+	fInputFiles     string
+	fOutputFormat   string
+	fFieldsFile     string
+	fFieldColors    string
+	fAttrs          string
+	fAttrField      string
+	fPathSyntax     string
+	fFollow         bool
+	fReplay         bool
+	fSpeed          float64
+	fSSE            bool
+	fWatchDir       string
+	fWatchPattern   string
+	fGlob           string
+	fRecursive      bool
+	fConcurrency    int
+	fMaxOpen        int
+	fSeparator      string
+	fJSON           bool
+	fPretty         bool
+	fOutput         string
+	fTemplate       string
+	fArraySep       string
+	fPrettyJSON     bool
+	fFlatten        bool
+	fJSONNested     bool
+	fMissing        string
+	fNullAs         string
+	fBoolFormat     string
+	fMinLevel       string
+	fLevelField     string
+	fLevels         string
+	fMatches        multiFlag
+	fNotMatches     multiFlag
+	fJSONAfter      bool
+	fPrefixField    string
+	fWheres         multiFlag
+	fOnBadNumber    string
+	fSince          string
+	fUntil          string
+	fOnBadTime      string
+	fColorMap       string
+	fKeyColor       string
+	fValueColor     string
+	fHighlight      string
+	fMaxLine        int
+	fMultilineStart string
+	fMaxWidth       string
+	fTruncateJSON   int
+	fPrettyOnWide   bool
+	fWideWidth      int
+	fTableRows      int
+	fTableBorder    bool
+	fWidths         string
+	fNumeric        string
+	fInput          string
+	fFormatDetect   bool
+	fOutFile        string
+	fOutAppend      bool
+	fQuiet          bool
+	fAutoColor      bool
+	fHeader         bool
+	fTimeField      string
+	fTimeFormat     string
+	fDurations      string
+	fDurationUnit   string
+	fSizes          string
+	fSizeBase       int
+	fTransforms     string
+	fMergeBy        string
+	fExclude        string
+	fAutoFields     bool
+	fTail           int
+	fFromOffset     int64
+	fSaveOffset     bool
+	fHead           uint64
+	fNoColor        bool
+	fFlushInterval  time.Duration
+	fBuffering      string
+	fStats          bool
+	fNoMatchExit    int
+	fStrict         bool
+	fFailFast       bool
+	fPassthrough    bool
+	fRaw            bool
+	fExplode        bool
+	fInvert         bool
+	fSample         string
+	fCount          string
+	fDedup          bool
+	fGroupBy        string
+	fContext        int
+	fPrefix         string
+	fSuffix         string
+	fDiff           bool
+	fLineNumbers    bool
+	fSrcLineNumbers bool
+	fDedupCount     bool
+	fDedupFields    string
+	fUniqField      string
+	fUniqKeep       string
+	fUniqMax        int
 )
 
 func init() {
-	flag.StringVar(&fInputFiles, "files", "", "List of path input log files, separated by comma (,)")
-	flag.StringVar(&fOutputFormat, "f", "", "Output format. Fields can be access by dot notation path, separated by comma (,)")
-	flag.StringVar(&fFieldColors, "colors", "", "Field colors")
+	flag.StringVar(&fConfig, "config", "", "Path to JSON config file of default flag values keyed by flag name (e.g. {\"f\": \"time,level,msg\", \"colors\": \"cyan,green\"}). Command line flags override config values")
+	flag.StringVar(&fProfile, "profile", "", "Name of the profile in --config to use (e.g. {\"profiles\": {\"nginx\": {\"f\": \"time,status,path\"}}})")
+	flag.StringVar(&fInputFiles, "files", "", "List of path input log files, separated by comma (,). Glob patterns (e.g. logs/*.log) are expanded, tcp://host:port and unix:///path/to/socket are read from sockets, http(s):// URLs are streamed by GET, - or /dev/stdin reads stdin")
+	flag.BoolVar(&fSSE, "sse", false, "Read http(s):// --files as Server-Sent Events streams, printing the data of each event as a line")
+	flag.StringVar(&fOutputFormat, "f", "", "Output format. Fields can be access by dot notation path, separated by comma (,). Field can be aliased by path:alias and colored by path#color (e.g. time#cyan,level:lvl#yellow). Wildcards user.* and **.id expand to one field per match. Array indexes can be negative, e.g. events.-1 is the last event. gjson queries (users.#.name) and modifiers (@reverse) are supported")
+	flag.StringVar(&fFieldsFile, "fields-file", "", "Read output fields of -f from file, separated by newline or comma (,). Lines starting with # are comments. Fields are appended after -f fields")
+	flag.StringVar(&fAttrs, "attr", "", "Output attributes of OpenTelemetry style logs by key after -f fields, separated by comma (,), e.g. http.method,http.status_code:status. Key/value pairs arrays and AnyValue wrappers are unwrapped")
+	flag.StringVar(&fAttrField, "attr-field", "attributes", "Field of attributes for --attr, as key/value pairs array or object keyed by attribute names")
+	flag.StringVar(&fPathSyntax, "path-syntax", nice.PathGJSON, "Syntax of paths in -f, --attr-field, --time-field, --level-field, --match, --where and --color-map: gjson (dot notation) or pointer (RFC 6901 JSON Pointer, e.g. /context/user/id). Paths not starting with / are gjson paths in pointer mode too")
+	flag.StringVar(&fExclude, "exclude", "", "Print all top-level fields except these, separated by comma (,). Only used when -f is not set")
+	flag.BoolVar(&fAutoFields, "auto-fields", false, "Print the top-level fields of the first JSON line, in rotating colors unless --colors is set, as stable columns of all lines. Only used when -f and --exclude are not set")
+	flag.BoolVar(&fFlatten, "flatten", false, "Expand selected object fields to one field per leaf value, named by dot notation path (e.g. context.user.id). Arrays are not expanded")
+	flag.BoolVar(&fNoColor, "no-color", false, "Disable colors. Colors are also disabled when NO_COLOR env is set or output is not a terminal")
+	flag.StringVar(&fFieldColors, "colors", "", "Field colors by position, separated by comma (,). A single color applies to all fields, fields without color are not colored. Color can be fg, bgcolor or fg:bg with optional +bold, +italic, +underline styles (e.g. red+bold,bgblue,black:bgwhite). Colors are names, xterm 256 palette codes (256:214) or hex RGB (#ff8800, nearest named color unless COLORTERM=truecolor)")
+	flag.BoolVar(&fAutoColor, "auto-color", false, "Color lines by the value of --level-field: error=red, warn=yellow, info=green, debug=cyan")
+	flag.StringVar(&fColorMap, "color-map", "", "Color whole line by field value, in form of field:value=color, separated by comma (,). Overrides --colors when matched")
+	flag.StringVar(&fKeyColor, "key-color", "", "Color of keys in JSON output and field labels in pretty output (e.g. faint)")
+	flag.StringVar(&fValueColor, "value-color", "", "Color of values in JSON output, and of values without --colors or --color-map color in pretty output")
+	flag.StringVar(&fHighlight, "highlight", "", "Highlight substrings of values matched by this regex in reverse video, like grep --color. Lines are not filtered")
+	flag.BoolVar(&fFollow, "follow", false, "Keep reading files when EOF reached and wait for new lines, like tail -f")
+	flag.BoolVar(&fReplay, "replay", false, "Pace lines of each input by the gaps between their --time-field times, to replay captured logs as a live stream. Merged inputs are paced as one stream by their --merge-by times")
+	flag.Float64Var(&fSpeed, "speed", 1, "Speed multiplier of --replay, e.g. 10 replays 10 times faster")
+	flag.BoolVar(&fFollow, "F", false, "Shorthand for --follow")
+	flag.StringVar(&fWatchDir, "watch-dir", "", "Follow all files in this directory matching --watch-pattern, including files created later (the directory is checked every second). Implies --follow")
+	flag.StringVar(&fGlob, "glob", "*", "Glob pattern of file names to read in directory entries of --files (e.g. *.log)")
+	flag.BoolVar(&fRecursive, "recursive", false, "Read files in sub-directories of directory entries of --files too")
+	flag.IntVar(&fConcurrency, "concurrency", 0, "Number of files read at the same time, in order as others finish. Default to the number of CPUs, at most --max-open. Followed or merged files are all read at the same time")
+	flag.IntVar(&fMaxOpen, "max-open", 64, "Maximum number of files opened at the same time. Followed or merged files must not exceed it, files found by --watch-dir beyond it are not followed")
+	flag.StringVar(&fWatchPattern, "watch-pattern", "*", "Glob pattern of file names to follow in --watch-dir (e.g. *.log)")
+	flag.StringVar(&fInput, "input", nice.InputJSON, "Input log format: json, logfmt, auto (detected per line: JSON, logfmt if line has key=value pairs, else plain text), csv or tsv (fields are columns named by the header row of each input)")
+	flag.BoolVar(&fFormatDetect, "format-detect", false, "Shorthand for --input auto --passthrough, to format JSON and logfmt lines of mixed streams and print plain text lines as is")
+	flag.BoolVar(&fJSONAfter, "json-after", false, "Parse JSON starting from the first { of line, ignoring the text prefix (e.g. docker/k8s timestamp or container name)")
+	flag.StringVar(&fPrefixField, "prefix-field", "", "Capture the text prefix skipped by --json-after as this field")
+	flag.IntVar(&fTail, "tail", 0, "Only process the last N lines of each file, then exit or keep following with --follow")
+	flag.Int64Var(&fFromOffset, "from-offset", 0, "Start reading files from this byte offset, or from the beginning if the file is shorter")
+	flag.BoolVar(&fSaveOffset, "save-offset", false, "Save the read offset of each file to <file>.niceoffset on exit and resume from it on the next run. Overrides --from-offset and --tail when saved")
+	flag.Uint64Var(&fHead, "head", 0, "Exit after printing N lines in total of all inputs")
+	flag.BoolVar(&fPassthrough, "passthrough", false, "Print lines which cannot be parsed (e.g. non-JSON) as is instead of skipping them")
+	flag.BoolVar(&fRaw, "raw", false, "Print lines passed the filters as is, e.g. to grep JSON lines by --match, --where or --min-level. Cannot be used with -f")
+	flag.BoolVar(&fExplode, "explode", false, "Print each element of lines having JSON array root, or each object of lines having multiple objects separated by spaces, as a separate line")
+	flag.StringVar(&fCount, "count", "", "Instead of printing lines, count the distinct values of this field and print them sorted by count at the end")
+	flag.BoolVar(&fInvert, "invert", false, "Invert filters (--min-level, --match, --not-match, --where, --since, --until) to print only lines they would drop")
+	flag.StringVar(&fSample, "sample", "", "Keep only a sample of lines passed the filters: 1 of every n lines (e.g. 1/100) or by probability (e.g. 0.01). Sampling is global across inputs")
+	flag.BoolVar(&fLineNumbers, "line-numbers", false, "Prefix output lines by their number, like grep -n. Input names are prefixed too with multiple inputs")
+	flag.BoolVar(&fSrcLineNumbers, "src-line-numbers", false, "Like --line-numbers but number by line of each input instead of printed lines")
+	flag.StringVar(&fGroupBy, "group-by", "", "Print a header line each time the value of this field changes, separating lines into groups (e.g. by request_id). Lines without the field stay in the current group")
+	flag.IntVar(&fContext, "context", 0, "Print N lines before and after each line passed the filters (--match, --where...), like grep -C. Overlapping contexts are merged, separated groups are split by --")
+	flag.StringVar(&fPrefix, "prefix", "", "Literal text written before each output line, e.g. '[api] '. {file} is replaced by the input name, e.g. '[{file}] ' to tell multiple files apart")
+	flag.StringVar(&fSuffix, "suffix", "", "Literal text written after each output line, before the newline. {file} is replaced by the input name")
+	flag.BoolVar(&fDiff, "diff", false, "Dim values which are the same as in the previous line of the same input and embolden changed ones, in text and pretty output. The first line is colored as usual")
+	flag.BoolVar(&fDedup, "dedup", false, "Collapse consecutive identical output lines into one")
+	flag.BoolVar(&fDedupCount, "dedup-count", false, "Append (xN) repeat count to lines collapsed by --dedup. A line is printed once its repeats end")
+	flag.StringVar(&fDedupFields, "dedup-fields", "", "Compare only these fields, separated by comma (,), instead of the whole output line for --dedup")
+	flag.StringVar(&fUniqField, "uniq-field", "", "Print only one line per value of this field (e.g. request_id) across all inputs. Lines without the field are always printed. Values seen are kept in memory, see --uniq-max")
+	flag.StringVar(&fUniqKeep, "uniq-keep", "first", "Line of each --uniq-field value to print: first, or last (lines are held until all inputs end)")
+	flag.IntVar(&fUniqMax, "uniq-max", 0, "Maximum number of --uniq-field values kept in memory, the oldest value is forgotten (or its last line printed) beyond it. 0 means unlimited")
+	flag.IntVar(&fMaxLine, "max-line", 1024*1024, "Maximum length of an input line in bytes. Longer lines are skipped with a warning")
+	flag.StringVar(&fMultilineStart, "multiline-start", "", "Join lines into one log line until the next line matching this regex (e.g. '^\\{' for pretty printed JSON). The last joined line is printed once its input ends")
+	flag.StringVar(&fMaxWidth, "max-width", "", "Truncate text output values longer than N runes with an ellipsis. Per field width by alias=N, separated by comma (,), e.g. 120,msg=80")
+	flag.IntVar(&fTruncateJSON, "truncate-json", 0, "Truncate object and array values longer than N characters with …, closing their quotes and brackets. Scalar values and --json output are not truncated. 0 means unlimited")
+	flag.BoolVar(&fPrettyOnWide, "pretty-print-on-wide", false, "Print lines wider than the terminal as blocks of label: value lines, as in --output block. Only applies to text output written to a terminal")
+	flag.IntVar(&fWideWidth, "wide-width", 0, "Width in columns above which --pretty-print-on-wide prints a line as a block. 0 means the terminal width")
+	flag.StringVar(&fWidths, "widths", "", "Pad text output fields by position to these widths, separated by comma (,). 0 means unconstrained, >N right-aligns (e.g. 20,>8,0). Numbers are always right-aligned")
+	flag.StringVar(&fNumeric, "numeric-fields", "", "Right-align these fields (or aliases) as numbers in --widths and table output, separated by comma (,). JSON numbers are right-aligned already")
+	flag.StringVar(&fOutFile, "out", "", "Write output to file instead of stdout. Can also be syslog:// for local syslog, or tcp://host:port, udp://host:port and unix:///path/to/socket to send lines to a remote collector")
+	flag.BoolVar(&fOutAppend, "out-append", false, "Append to --out file instead of truncating it")
+	flag.BoolVar(&fQuiet, "quiet", false, "Suppress informational logs, only errors are logged")
+	flag.BoolVar(&fQuiet, "q", false, "Shorthand for --quiet")
+	flag.DurationVar(&fFlushInterval, "flush-interval", 200*time.Millisecond, "Flush block buffered output on this interval. Set to 0 to write every line immediately, or to flush only when the buffer is full with --buffering block")
+	flag.StringVar(&fBuffering, "buffering", "auto", "Output buffering: line (write every line immediately), block (buffer output, flushed by --flush-interval) or auto (line if output is a terminal, block otherwise)")
+	flag.BoolVar(&fStats, "stats", false, "Print lines statistics of each input to stderr on exit")
+	flag.IntVar(&fNoMatchExit, "no-match-exit", 1, "Exit code when no lines were printed, like grep. Set to 0 to always exit 0")
+	flag.BoolVar(&fStrict, "strict", false, "Log lines which cannot be parsed by --input format and exit with code 2 if there's any, e.g. to validate JSON lines in CI")
+	flag.BoolVar(&fFailFast, "fail-fast", false, "Like --strict but stop reading at the first invalid line")
+	flag.BoolVar(&fHeader, "header", false, "Print field names (or aliases) as the first output line. With --flatten, --exclude, --auto-fields or wildcard fields, names are taken from the first printed line")
+	flag.StringVar(&fTimeField, "time-field", "time", "Field of log time, in dot notation path")
+	flag.StringVar(&fTimeFormat, "time-format", "", "Reformat --time-field by Go time layout (e.g. 15:04:05). Raw value is printed if time cannot be parsed")
+	flag.StringVar(&fDurations, "duration-fields", "", "Print these numeric fields (or aliases) as human-readable durations (e.g. 123.4ms), separated by comma (,). Non-numeric values are printed as is")
+	flag.StringVar(&fDurationUnit, "duration-unit", "ns", "Unit of --duration-fields values: ns, us, ms or s")
+	flag.StringVar(&fSizes, "size-fields", "", "Print these numeric fields (or aliases) as human-readable byte sizes (e.g. 1.2MB), separated by comma (,). Non-numeric values are printed as is")
+	flag.IntVar(&fSizeBase, "size-base", 10, "Base of --size-fields units: 10 (KB, MB...) or 2 (KiB, MiB...)")
+	flag.StringVar(&fTransforms, "transform", "", "Transform field (or alias) values in non-JSON output, in form of alias=name, separated by comma (,). Transforms: upper, lower, trim, basename, json-compact. Transforms of the same alias are applied in order")
+	flag.StringVar(&fMergeBy, "merge-by", "", "Merge lines from multiple files in order of this time field. Each file must be ordered by time already")
+	flag.StringVar(&fSeparator, "sep", "\t", "Separator between output fields")
+	flag.StringVar(&fArraySep, "array-sep", "", "Join array values (e.g. from gjson queries like users.#.name) by this separator instead of printing raw JSON array")
+	flag.BoolVar(&fPrettyJSON, "pretty-json", false, "Indent and highlight object or array field values in text output")
+	flag.StringVar(&fOutput, "output", nice.OutputText, "Output format: text (separated values), json, csv, table (shrunk to the terminal width) or pretty (one field: value line per field, blank line between lines)")
+	flag.IntVar(&fTableRows, "table-rows", 100, "Number of lines buffered to compute column widths of each table in table output")
+	flag.BoolVar(&fTableBorder, "table-border", false, "Draw box borders around table output cells")
+	flag.BoolVar(&fJSON, "json", false, "Shorthand for --output json")
+	flag.BoolVar(&fPretty, "pretty", false, "Shorthand for --output pretty")
+	flag.StringVar(&fTemplate, "template", "", "Format lines by Go text/template, e.g. '{{.time}} [{{.level}}] {{.msg}}'. Template data are the output fields, or all fields if -f is not set")
+	flag.BoolVar(&fJSONNested, "json-nested", false, "In JSON output, expand dot notation fields into nested objects instead of flattened keys")
+	flag.StringVar(&fMissing, "missing", "", "Placeholder printed in place of missing fields to keep columns aligned. Missing fields are skipped if not set")
+	flag.StringVar(&fNullAs, "null-as", "", "Placeholder printed in place of null fields (e.g. null) in non-JSON output, to tell them from missing fields. Null fields are treated as missing if not set")
+	flag.StringVar(&fBoolFormat, "bool-format", "", "Print boolean fields as these symbols in non-JSON output, in form of true=S,false=S (e.g. true=✓,false=✗). String values are printed as is")
+	flag.StringVar(&fMinLevel, "min-level", "", "Drop lines having level lower than this level. Lines with unknown level are kept")
+	flag.StringVar(&fLevelField, "level-field", "level", "Field of log level, in dot notation path")
+	flag.StringVar(&fLevels, "levels", nice.DefaultLevels, "Log levels ordered by severity from lowest to highest, separated by comma (,)")
+	flag.Var(&fMatches, "match", "Keep only lines having field matched regex (field=~regex) or not matched (field!~regex). Can be repeated, all clauses must pass")
+	flag.Var(&fNotMatches, "not-match", "Drop lines having field matched regex (field=~regex). Can be repeated, lines must pass all --match and --not-match clauses")
+	flag.Var(&fWheres, "where", "Keep only lines having numeric field compared to number, e.g. status>=400. Operators: >, >=, <, <=, ==, !=. Can be repeated, all clauses must pass")
+	flag.StringVar(&fOnBadNumber, "on-bad-number", "drop", "Policy for lines with missing or non-numeric --where field: keep or drop")
+	flag.StringVar(&fSince, "since", "", "Keep only lines with --time-field at or after this time. RFC3339 time or duration before now (e.g. 2019-06-24T10:00:00Z, -1h)")
+	flag.StringVar(&fUntil, "until", "", "Keep only lines with --time-field before this time. RFC3339 time or duration before now (e.g. -30m)")
+	flag.StringVar(&fOnBadTime, "on-bad-time", "keep", "Policy for lines with missing or unparseable --time-field when --since or --until is set: keep or drop")
 }
 
 func main() {
 	flag.Usage = func() {
 		flag.PrintDefaults()
 		fmt.Println(`
+Flags not set on command line are read from NICE_<FLAG> environment variables
+(e.g. NICE_TIME_FORMAT for --time-format, NICE_FIELDS for -f), then from --config.
+
 Examples:
   $ nice --files 20190624.log -f time,msg
   $ myapp | nice -f time,level,msg
-  $ myapp | nice --files 20190624.log,anotherlogfile.log -f time,level,msg,field.child.id`)
+  $ myapp | nice --files -,20190624.log -f time,level,msg
+  $ myapp | nice --files 20190624.log,anotherlogfile.log -f time,level,msg,field.child.id
+  $ nice --files 20190624.log -f time:ts,level:lvl,msg:message --json
+  $ nice --files 20190624.log -f time,msg --time-format 15:04:05
+  $ nice --files 20190624.log -f time,path,latency --duration-fields latency --duration-unit us
+  $ nice --files access.log -f time,path,bytes --size-fields bytes --size-base 2
+  $ nice --files access.log -f time,level,path --transform level=upper,path=basename
+  $ nice --files 20190624.log -f time#cyan,level#yellow+bold,msg
+  $ nice --files 20190624.log -f time#256:244,level##ff8800,msg
+  $ nice --files 20190624.log --template '{{.time}} [{{.level}}] {{.msg}}'
+  $ nice --files 20190624.log --exclude stacktrace,headers
+  $ myapp | nice --auto-fields
+  $ nice --files 20190624.log -f time,level,msg,ctx --pretty --pretty-json
+  $ nice --files 20190624.log -f time,msg,request --truncate-json 80
+  $ nice --files 20190624.log -f time,user,msg --null-as null --missing -
+  $ nice --files flags.log -f time,flag,enabled --bool-format 'true=✓,false=✗'
+  $ myapp | nice -f time,level,msg,error,request --pretty-print-on-wide
+  $ nice --files 20190624.log -f time,level,msg --json --key-color faint --value-color green
+  $ nice --files otel.json -f time,body --attr http.method,http.status_code:status
+  $ nice --files 20190624.log --path-syntax pointer -f /time,/context/user/id:user,/msg
+  $ nice --input csv --files report.csv -f date,user,amount --output table
+  $ nice --files 'logs/*.log' -f time,level,msg
+  $ nice --files logs/ --recursive --glob '*.log' -f time,level,msg
+  $ nice -F --files 20190624.log -f time,level,msg
+  $ nice --replay --speed 10 --files 20190624.log | myconsumer
+  $ nice -F --files tcp://localhost:5000 -f time,level,msg
+  $ nice -F --sse --files http://localhost:8080/logs -f time,level,msg
+  $ nice -F --tail 10 --files 20190624.log -f time,level,msg
+  $ nice -F --save-offset --files 20190624.log -f time,level,msg
+  $ nice --watch-dir logs --watch-pattern '*.log' -f time,level,msg
+  $ nice --tail 1000 --since -15m --files 20190624.log -f time,level,msg
+  $ nice --files access.log -f time,path,status --where 'status>=500'
+  $ nice --files access.log -f time,path,status --match 'path=~^/api' --not-match 'path=~/health'
+  $ nice --files 20190624.log -f time,level,msg --min-level error --context 5
+  $ nice --files 20190624.log --raw --where 'status>=500'
+  $ docker logs app 2>&1 | nice --format-detect -f time,level,msg
+  $ nice -q --files access.log -f path --where 'status>=500' > /dev/null || echo "no errors"
+  $ nice -q --strict --files 20190624.log > /dev/null && echo "valid JSON lines"
+  $ nice --files 20190624.log -f time,msg --highlight 'timeout|refused'
+  $ nice --files 20190624.log --count level
+  $ nice --files 20190624.log -f time,level,msg --group-by request_id
+  $ nice --files 'logs/*.log' -f time,request_id,status --uniq-field request_id --uniq-keep last
+  $ nice -F --files fsm.log -f time,state,retries,leader --diff
+  $ nice --files 'logs/*.log' -f time,msg --match 'msg=~timeout' --src-line-numbers
+  $ nice --files api.log,worker.log -f time,level,msg --prefix '[{file}] '
+  $ nice --files 20190624.log -f time,msg --head 20
+  $ nice --files 20190624.log -f time,level,msg --output table --table-border
+  $ nice --files 20190623.log.gz -f time,msg
+  $ nice --files pretty.log --multiline-start '^\{' -f time,msg
+  $ docker compose logs app | nice --json-after --prefix-field container -f container,level,msg
+  $ nice --config myapp.json --files 20190624.log
+  $ nice --fields-file myapp.fields --files 20190624.log
+  $ nice --config services.json --profile nginx --files access.log
+  $ NICE_FIELDS=time,level,msg NICE_COLORS=cyan,yellow nice --files 20190624.log
+  $ nice --files 20190624.log -f time,msg --out filtered.log
+  $ nice -F --files 20190624.log -f time,level,msg --out tcp://collector:5000
+  $ nice -F --files 20190624.log -f time,level,msg --buffering line | grep timeout`)
 	}
 	flag.Parse()
+	if err := loadEnv(os.LookupEnv); err != nil {
+		log.Fatalf("nice: invalid environment variable: %v", err)
+	}
+	if fConfig != "" {
+		if err := loadConfig(fConfig, fProfile); err != nil {
+			log.Fatalf("nice: invalid --config: %v", err)
+		}
+	} else if fProfile != "" {
+		log.Fatalf("nice: invalid --profile: --config is required")
+	}
 
+	if fFieldsFile != "" {
+		fields, err := readFieldsFile(fFieldsFile)
+		if err != nil {
+			log.Fatalf("nice: invalid --fields-file: %v", err)
+		}
+		if fOutputFormat != "" && fields != "" {
+			fOutputFormat += ","
+		}
+		fOutputFormat += fields
+	}
+
+	if fWatchDir != "" {
+		if _, err := filepath.Match(fWatchPattern, ""); err != nil {
+			log.Fatalf("nice: invalid --watch-pattern: %v", err)
+		}
+		fFollow = true
+	}
+	if _, err := filepath.Match(fGlob, ""); err != nil {
+		log.Fatalf("nice: invalid --glob: %v", err)
+	}
+	if fMultilineStart != "" {
+		var err error
+		if multilineStart, err = regexp.Compile(fMultilineStart); err != nil {
+			log.Fatalf("nice: invalid --multiline-start: %v", err)
+		}
+	}
+	if fFromOffset < 0 {
+		log.Fatalf("nice: invalid --from-offset: must not be negative")
+	}
+	if fFromOffset > 0 && fTail > 0 {
+		log.Fatalf("nice: invalid --from-offset: cannot be used with --tail")
+	}
+	if fMaxLine < 1 {
+		log.Fatalf("nice: invalid --max-line: must be positive")
+	}
+	if fRaw && (fOutputFormat != "" || fAttrs != "") {
+		log.Fatalf("nice: invalid --raw: cannot be used with -f or --attr")
+	}
+	switch fBuffering {
+	case "auto", "line", "block":
+	default:
+		log.Fatalf("nice: invalid --buffering %q, expecting auto, line or block", fBuffering)
+	}
+	if fSpeed <= 0 {
+		log.Fatalf("nice: invalid --speed: must be positive")
+	}
+	if fWideWidth < 0 {
+		log.Fatalf("nice: invalid --wide-width: must not be negative")
+	}
+	if fConcurrency < 0 {
+		log.Fatalf("nice: invalid --concurrency: must not be negative")
+	} else if fConcurrency == 0 {
+		fConcurrency = runtime.NumCPU()
+	}
+	if fMaxOpen < 1 {
+		log.Fatalf("nice: invalid --max-open: must be at least 1")
+	}
+	if fConcurrency > fMaxOpen {
+		fConcurrency = fMaxOpen
+	}
 	var fileStrs []string
+	readStdin := false
 	if fInputFiles != "" {
-		fileStrs = strings.Split(fInputFiles, ",")
+		fileStrs, readStdin = splitStdin(expandFiles(strings.Split(fInputFiles, ",")))
+	}
+	// Followed and merged files are read at the same time until exit
+	if (fFollow || fMergeBy != "") && len(fileStrs) > fMaxOpen {
+		log.Fatalf("nice: %d files to read at the same time, more than --max-open %d", len(fileStrs), fMaxOpen)
 	}
-	outFields := strings.Split(fOutputFormat, ",")
-	outColors := getColorFormat(fFieldColors)
-
-	outputWriter := os.Stdout
-
-	// Read from stdin
 	fi, err := os.Stdin.Stat()
 	if err != nil {
 		log.Panicf("nice: failed to get stdin info")
 	}
-	// Standalone rune without stdin pipe (|) => Skip reading from stdin
-	isPiped := (fi.Mode() & os.ModeCharDevice) == 0
-	if isPiped {
-		// This goroutine continue running until the app stopped
-		go func() {
-			log.Printf("nice: start reading from stdin")
-			pipeStdin(outFields, outColors, outputWriter)
-		}()
+	// Standalone rune without stdin pipe (|) => Skip reading from stdin, unless explicitly set by --files -
+	readStdin = readStdin || (fi.Mode()&os.ModeCharDevice) == 0
+	if fJSON {
+		fOutput = nice.OutputJSON
+	}
+	if fFormatDetect {
+		fInput = nice.InputAuto
+		fPassthrough = true
+	}
+	if fPretty {
+		fOutput = nice.OutputPretty
+	}
+	termWidth, wrapWidth := 0, 0 // Only applied to terminal output
+	if fOutFile == "" && isTerminal(os.Stdout) {
+		termWidth, _ = terminalWidth(os.Stdout)
+		if fPrettyOnWide {
+			wrapWidth = fWideWidth
+			if wrapWidth == 0 {
+				wrapWidth = termWidth
+			}
+		}
+	}
+	f, err := nice.NewFormatter(nice.Options{
+		Input:        formatterInput(fInput),
+		JSONAfter:    fJSONAfter,
+		PrefixField:  fPrefixField,
+		Explode:      fExplode,
+		Passthrough:  fPassthrough,
+		Raw:          fRaw,
+		Fields:       fOutputFormat,
+		Attrs:        fAttrs,
+		AttrField:    fAttrField,
+		PathSyntax:   fPathSyntax,
+		Exclude:      fExclude,
+		AutoFields:   fAutoFields,
+		Flatten:      fFlatten,
+		Output:       fOutput,
+		Template:     fTemplate,
+		Separator:    fSeparator,
+		ArraySep:     fArraySep,
+		JSONNested:   fJSONNested,
+		PrettyJSON:   fPrettyJSON,
+		MaxLine:      fMaxLine,
+		MaxWidth:     fMaxWidth,
+		TruncateJSON: fTruncateJSON,
+		WrapWidth:    wrapWidth,
+		TableWidth:   termWidth,
+		Widths:       fWidths,
+		Numeric:      fNumeric,
+		TableRows:    fTableRows,
+		TableBorder:  fTableBorder,
+		Missing:      fMissing,
+		ShowMissing:  isFlagSet("missing"), // Allow empty placeholder if explicitly set
+		NullAs:       fNullAs,
+		BoolFormat:   fBoolFormat,
+		TimeField:    fTimeField,
+		TimeFormat:   fTimeFormat,
+		Durations:    fDurations,
+		DurationUnit: fDurationUnit,
+		Sizes:        fSizes,
+		SizeBase:     fSizeBase,
+		Transforms:   fTransforms,
+		Colors:       fFieldColors,
+		ColorMap:     fColorMap,
+		KeyColor:     fKeyColor,
+		ValueColor:   fValueColor,
+		Highlight:    fHighlight,
+		AutoColor:    fAutoColor,
+		LevelField:   fLevelField,
+		MinLevel:     fMinLevel,
+		Levels:       fLevels,
+		Matches:      fMatches,
+		NotMatches:   fNotMatches,
+		Wheres:       fWheres,
+		OnBadNumber:  fOnBadNumber,
+		Since:        fSince,
+		Until:        fUntil,
+		OnBadTime:    fOnBadTime,
+		Invert:       fInvert,
+		Sample:       fSample,
+	})
+	if err != nil {
+		log.Fatalf("nice: invalid options: %v", err)
+	}
+	p := &printer{f: f, head: fHead}
+	if fCount != "" {
+		p.counter = newValueCounter(fCount)
+	}
+	if fDedup {
+		p.dedup = newDeduper(fDedupFields, fDedupCount)
+	}
+	if fGroupBy != "" {
+		p.group = newGrouper(fGroupBy)
+	}
+	if fUniqField != "" {
+		switch fUniqKeep {
+		case "first", "last":
+		default:
+			log.Fatalf("nice: invalid --uniq-keep %q, expecting first or last", fUniqKeep)
+		}
+		p.uniq = newUniqFilter(fUniqField, fUniqKeep == "last", fUniqMax)
+	}
+	if fStrict || fFailFast {
+		p.strict = &parseChecker{failFast: fFailFast}
+	}
+	if fContext < 0 {
+		log.Fatalf("nice: invalid --context: must not be negative")
+	}
+	p.context = fContext
+	if fPrefix != "" || fSuffix != "" {
+		p.affix = newLineAffix(fPrefix, fSuffix)
+	}
+	p.diff = fDiff
+	if fLineNumbers || fSrcLineNumbers {
+		inputs := len(fileStrs)
+		if readStdin {
+			inputs++
+		}
+		p.numbers = newLineNumberer(fSrcLineNumbers, inputs > 1 || fWatchDir != "")
 	}
 
-	wg := sync.WaitGroup{}
 	ctx, ctxCancel := context.WithCancel(context.Background())
-	for _, inFile := range fileStrs {
+	p.stop = ctxCancel
+	if fReplay {
+		p.replay = newReplayer(fTimeField, fSpeed, ctx.Done())
+	}
+
+	// Handle broken output pipe as an error instead of being killed by SIGPIPE
+	signal.Ignore(syscall.SIGPIPE)
+	outputWriter, perMessage, err := openOutput(fOutFile, fOutAppend)
+	if err != nil {
+		log.Fatalf("nice: failed to open output %v: %v", fOutFile, err)
+	}
+	outFile, ok := outputWriter.(*os.File)
+	tty := ok && isTerminal(outFile)
+	if fNoColor || os.Getenv("NO_COLOR") != "" || !tty {
+		color.NoColor = true
+	}
+
+	// All inputs write to the same output concurrently
+	var out io.Writer = newSyncWriter(outputWriter)
+	var buffOut *bufferedWriter
+	lineBuffered := fBuffering == "line" || (fBuffering == "auto" && (tty || fFlushInterval == 0))
+	if !lineBuffered && !perMessage { // Lines must not be batched into one message
+		buffOut = newBufferedWriter(outputWriter, fFlushInterval, func(err error) {
+			p.writeFailed(err, nil)
+		})
+		out = buffOut
+	}
+
+	// Header must be written once before any input is processed
+	if fHeader {
+		p.printHeader(out)
+	}
+
+	// Read from stdin
+	wg := sync.WaitGroup{}
+	if readStdin {
 		wg.Add(1)
-		go pipeFile(ctx, &wg, inFile, outFields, outColors, outputWriter)
+		go pipeStdin(ctx, &wg, p, out)
+	}
+	if fMergeBy != "" && len(fileStrs) > 1 {
+		wg.Add(1)
+		go mergeFiles(ctx, &wg, fileStrs, fMergeBy, p, out)
+	} else if !fFollow {
+		wg.Add(1)
+		go pipeFiles(ctx, &wg, fileStrs, fConcurrency, p, out)
+	} else { // Followed files never finish so they can't wait for others
+		for _, inFile := range fileStrs {
+			wg.Add(1)
+			go pipeFile(ctx, &wg, inFile, p, out)
+		}
 	}
 
-	// Trap signal if reading from stdin
-	if isPiped {
-		stopChan := make(chan os.Signal, 1)
-		signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM)
-		sig := <-stopChan
-		log.Printf("nice: %s signal received. Start exiting", sig)
-		ctxCancel() // Notify background processes to stop
+	if fWatchDir != "" {
+		wg.Add(1)
+		go watchDir(ctx, &wg, fWatchDir, fWatchPattern, p, out)
 	}
 
+	// Trap signal so inputs are stopped and output is closed gracefully
+	stopChan := make(chan os.Signal, 1)
+	signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-stopChan
+		logInfof("nice: %s signal received. Start exiting", sig)
+		ctxCancel() // Notify background processes to stop
+	}()
+	// Wait for all inputs to be drained (EOF) or stopped by signal before closing output
 	wg.Wait()
-	if err := outputWriter.Close(); err != nil {
-		log.Panicf("nice: failed to close output writer")
+	p.flush(out)
+	if p.counter != nil {
+		if err := p.counter.print(out); err != nil {
+			log.Printf("nice: failed to write counts to output: %v", err)
+		}
+	}
+	if buffOut != nil {
+		if err := buffOut.Close(); err != nil && !isBrokenPipe(err) {
+			log.Printf("nice: failed to flush output: %v", err)
+		}
+	}
+	if fStats {
+		inputStats.print(os.Stderr)
+	}
+	if err := outputWriter.Close(); err != nil && !isBrokenPipe(err) {
+		log.Printf("nice: failed to close output: %v", err)
+	}
+	if p.strict != nil && p.strict.failed() {
+		logInfof("nice: %d invalid lines. Exit with code %d", atomic.LoadUint64(&p.strict.invalid), strictExitCode)
+		os.Exit(strictExitCode)
+	}
+	if fNoMatchExit != 0 && !p.matched() {
+		logInfof("nice: no lines printed. Exit with code %d", fNoMatchExit)
+		os.Exit(fNoMatchExit)
 	}
-	log.Println("nice: exit")
+	logInfof("nice: exit")
 }
 
-func pipeStdin(outputFields []string, outColors []*color.Color, out io.Writer) {
-	reader := bufio.NewReader(os.Stdin)
+func pipeStdin(ctx context.Context, wg *sync.WaitGroup, p *printer, out io.Writer) {
+	defer wg.Done()
+	logInfof("nice: start reading from stdin")
 
+	// Reading from stdin blocks until the next line and can't be cancelled,
+	// so the scanner is not waited for after ctx cancelled. The mutex makes sure
+	// the in-flight line is fully printed and no more lines are printed after that.
+	var mu sync.Mutex
 	buff := bytes.NewBuffer(make([]byte, 0, 1024))
-	for {
-		line, _, err := reader.ReadLine()
-		if err != nil && err == io.EOF {
-			return
-		}
+	st := inputStats.add("stdin")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanLines(ctx, "stdin", os.Stdin, func(line []byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			if ctx.Err() != nil {
+				return
+			}
+			buff.Reset()
+			st.record(p.print(st, line, buff, out))
+		})
+	}()
 
-		// Grep JSON
-		buff.Reset()
-		print(line, outputFields, outColors, buff, out)
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logInfof("nice: [stdin]: context cancel reveiced. Exit")
+		mu.Lock()
+		mu.Unlock()
 	}
 }
 
-func pipeFile(ctx context.Context, wg *sync.WaitGroup, filepath string, outputFields []string, outColors []*color.Color, out io.Writer) {
+func pipeFile(ctx context.Context, wg *sync.WaitGroup, filepath string, p *printer, out io.Writer) {
 	defer wg.Done()
 
+	buff := bytes.NewBuffer(make([]byte, 0, 1024))
+	st := inputStats.add(filepath)
+	scanFile(ctx, filepath, func(line []byte) {
+		buff.Reset()
+		st.record(p.print(st, line, buff, out))
+	})
+}
+
+// scanFile reads file line by line and calls fn on each line until EOF or ctx cancelled.
+// The line is only valid until fn returns.
+func scanFile(ctx context.Context, filepath string, fn func(line []byte)) {
+	if isSocketURL(filepath) {
+		scanSocket(ctx, filepath, fn)
+		return
+	}
+	if isHTTPURL(filepath) {
+		scanHTTP(ctx, filepath, fn)
+		return
+	}
+
 	f, err := os.OpenFile(filepath, os.O_RDONLY, 0400)
 	if err != nil {
 		log.Printf("nice: failed to open file %v: %v", filepath, err)
 		return
 	}
+	gzipped := isGzip(f, filepath)
+	var tracker *offsetTracker
+	if fFromOffset > 0 || fSaveOffset {
+		if tracker = startOffset(f, filepath, gzipped); tracker == nil {
+			_ = f.Close()
+			return
+		}
+	}
+	if fTail > 0 && tracker == nil {
+		if gzipped {
+			// Compressed file cannot be read backwards, keep the last lines while scanning instead
+			ring := newLineRing(fTail)
+			defer ring.flush(fn)
+			fn = ring.add
+		} else if _, err := seekTail(f, fTail); err != nil {
+			log.Printf("nice: [%v]: failed to seek to last %d lines: %v", filepath, fTail, err)
+		}
+	}
+
+	// In follow mode the reader owns the file as it may be reopened on rotation
+	var r io.ReadCloser = f
+	if fFollow {
+		fr := newFollowReader(ctx, filepath, f)
+		if tracker != nil {
+			fr.onReset = tracker.reset
+		}
+		r = fr
+	}
 	defer func() {
-		if err := f.Close(); err != nil {
+		if err := r.Close(); err != nil {
 			log.Printf("nice: failed to close file %v: %v", filepath, err)
 		}
 	}()
-	scanner := bufio.NewScanner(f)
 
-	buff := bytes.NewBuffer(make([]byte, 0, 1024))
-	for {
-		select {
-		case <-ctx.Done():
-			log.Printf("nice: [%v]: context cancel reveiced. Exit", filepath)
+	var in io.Reader = r
+	if gzipped {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			log.Printf("nice: [%v]: failed to read gzip file: %v", filepath, err)
 			return
-		default:
-			if !scanner.Scan() {
-				if err := scanner.Err(); err != nil {
-					log.Printf("nice: [%v]: file scanner error: %v", err)
-				} else {
-					log.Printf("nice: [%v]: all logs processed (EOF). Exit", filepath)
-				}
-				return
+		}
+		defer func() {
+			if err := gz.Close(); err != nil {
+				log.Printf("nice: [%v]: failed to close gzip reader: %v", filepath, err)
 			}
+		}()
+		in = gz
+	}
+	if tracker == nil {
+		scanLines(ctx, filepath, in, fn)
+		return
+	}
+	scan(ctx, filepath, newLineScanner(filepath, in, tracker.add), fn)
+	if fSaveOffset {
+		if err := saveOffset(filepath, atomic.LoadInt64(&tracker.offset)); err != nil {
+			log.Printf("nice: [%v]: failed to save offset: %v", filepath, err)
+		}
+	}
+}
 
-			buff.Reset()
-			print(scanner.Bytes(), outputFields, outColors, buff, out)
+// startOffset seeks f to the saved offset of --save-offset, or --from-offset, or to the
+// last --tail lines if no offset is saved yet, and returns the tracker of its read offset.
+// It returns nil if f cannot be read from offset.
+func startOffset(f *os.File, filepath string, gzipped bool) *offsetTracker {
+	if gzipped {
+		log.Printf("nice: [%v]: cannot read gzip file from offset", filepath)
+		return nil
+	}
+	offset, resumed := fFromOffset, false
+	if fSaveOffset {
+		saved, ok, err := loadOffset(filepath)
+		if err != nil {
+			log.Printf("nice: [%v]: failed to load offset: %v", filepath, err)
+			return nil
+		}
+		if ok {
+			offset, resumed = saved, true
+			logInfof("nice: [%v]: resume from offset %d", filepath, offset)
 		}
 	}
+	if fTail > 0 && !resumed { // First run, --from-offset cannot be used with --tail
+		offset, err := seekTail(f, fTail)
+		if err == nil {
+			return &offsetTracker{offset: offset}
+		}
+		log.Printf("nice: [%v]: failed to seek to last %d lines: %v", filepath, fTail, err)
+	}
+	offset, err := seekOffset(f, offset)
+	if err != nil {
+		log.Printf("nice: [%v]: failed to seek to offset %d: %v", filepath, offset, err)
+		return nil
+	}
+	return &offsetTracker{offset: offset}
 }
 
-func print(line []byte, outFields []string, outColors []*color.Color, buff *bytes.Buffer, out io.Writer) {
-	jsonLine := gjson.ParseBytes(line)
+// scanLines reads r line by line and calls fn on each line until EOF or ctx cancelled.
+func scanLines(ctx context.Context, name string, r io.Reader, fn func(line []byte)) {
+	scan(ctx, name, newLineScanner(name, r, nil), fn)
+}
 
-	for idx, field := range outFields {
-		jsField := jsonLine.Get(field)
-		val := jsField.Str
-		if jsField.Type == gjson.JSON {
-			val = jsField.Raw
+// scan calls fn on each token of scanner until EOF or ctx cancelled.
+// Lines are joined into records by --multiline-start if set.
+func scan(ctx context.Context, name string, scanner *bufio.Scanner, fn func(line []byte)) {
+	switch fInput {
+	case inputCSV:
+		fn = newCSVRows(',', name, fMaxLine, fn).add
+	case inputTSV:
+		fn = newCSVRows('\t', name, fMaxLine, fn).add
+	}
+	if multilineStart != nil {
+		joiner := newLineJoiner(multilineStart, fMaxLine, fn)
+		defer joiner.flush() // Last record ends at EOF
+		fn = joiner.add
+	}
+	err := nice.ScanLines(ctx, scanner, func(line []byte) error {
+		fn(line)
+		return nil
+	})
+	switch {
+	case ctx.Err() != nil:
+		logInfof("nice: [%v]: context cancel reveiced. Exit", name)
+	case err != nil:
+		log.Printf("nice: [%v]: file scanner error: %v", name, err)
+	default:
+		logInfof("nice: [%v]: all logs processed (EOF). Exit", name)
+	}
+}
+
+// expandFiles expands glob patterns and directories in input file entries.
+// Entries without pattern are kept as is.
+func expandFiles(entries []string) []string {
+	var files []string
+	for _, entry := range entries {
+		// URLs may have ? of query string
+		if isSocketURL(entry) || isHTTPURL(entry) {
+			files = append(files, entry)
+			continue
 		}
-		if strings.TrimSpace(val) == "" {
+		matches := []string{entry}
+		if strings.ContainsAny(entry, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(entry); err != nil {
+				log.Printf("nice: invalid file pattern %v: %v", entry, err)
+				continue
+			}
+			if len(matches) == 0 {
+				log.Printf("nice: file pattern %v matches no files", entry)
+				continue
+			}
+		}
+		for _, path := range matches {
+			if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+				files = append(files, dirFiles(path)...)
+				continue
+			}
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// dirFiles returns the files in dir with names matching --glob, in lexical order.
+// Sub-directories are walked only if --recursive is set.
+func dirFiles(dir string) []string {
+	var files []string
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			log.Printf("nice: [%v]: failed to read: %v", path, err)
+			return nil
+		}
+		if fi.IsDir() {
+			if path != dir && !fRecursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ok, _ := filepath.Match(fGlob, fi.Name()); ok && fi.Mode().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("nice: [%v]: failed to list files: %v", dir, err)
+	}
+	if len(files) == 0 {
+		log.Printf("nice: directory %v has no files matching %v", dir, fGlob)
+	}
+	return files
+}
+
+// pipeFiles pipes files in order by a pool of workers, so at most workers files are
+// opened at the same time. Files not started yet are skipped once ctx cancelled.
+func pipeFiles(ctx context.Context, wg *sync.WaitGroup, files []string, workers int, p *printer, out io.Writer) {
+	defer wg.Done()
+
+	queue := make(chan string)
+	workerWg := sync.WaitGroup{}
+	defer workerWg.Wait()
+	defer close(queue) // Stop workers once the queued files are done
+	if workers > len(files) {
+		workers = len(files)
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for path := range queue {
+				pipeFile(ctx, &workerWg, path, p, out)
+			}
+		}()
+	}
+	for _, path := range files {
+		workerWg.Add(1)
+		select {
+		case queue <- path:
+		case <-ctx.Done():
+			workerWg.Done()
+			return
+		}
+	}
+}
+
+// splitStdin removes stdin entries (- or /dev/stdin) from files
+// and reports whether there's any, so stdin is only read once by pipeStdin.
+func splitStdin(files []string) ([]string, bool) {
+	paths := files[:0]
+	stdin := false
+	for _, path := range files {
+		if path == "-" || path == "/dev/stdin" {
+			stdin = true
 			continue
 		}
+		paths = append(paths, path)
+	}
+	return paths, stdin
+}
+
+// newLineScanner returns a line scanner of input name accepting lines up to --max-line bytes.
+// Longer lines are skipped with a warning. onRead is called with the number of consumed bytes if not nil.
+func newLineScanner(name string, r io.Reader, onRead func(n int)) *bufio.Scanner {
+	splitter := &nice.LineSplitter{
+		Max:    fMaxLine,
+		OnRead: onRead,
+		OnSkip: func() { log.Printf("nice: [%v]: skipped line longer than %d bytes (--max-line)", name, fMaxLine) },
+	}
+	return splitter.Scanner(r)
+}
+
+// isGzip reports whether f is a gzip file by its extension or magic bytes.
+func isGzip(f *os.File, filepath string) bool {
+	if strings.HasSuffix(strings.ToLower(filepath), ".gz") {
+		return true
+	}
+	magic := make([]byte, 2)
+	if _, err := f.ReadAt(magic, 0); err != nil {
+		return false
+	}
+	return magic[0] == 0x1f && magic[1] == 0x8b
+}
+
+// printer prints formatted lines of all inputs to the output.
+type printer struct {
+	f       *nice.Formatter
+	dedup   *deduper      // Collapse consecutive repeated lines if set
+	uniq    *uniqFilter   // Keep one line per field value if set
+	group   *grouper      // Print group headers when the group field value changes if set
+	numbers *lineNumberer // Prefix lines by line numbers if set
+	affix   *lineAffix    // Wrap lines between literal prefix and suffix if set
+
+	context    int // Number of context lines around lines passed the filters
+	contextsMu sync.Mutex
+	contexts   map[*lineStats]*contextLines // Context lines by input
+	diff       bool                         // Compare output values to the previous line of the same input
+	diffsMu    sync.Mutex
+	diffs      map[*lineStats]*nice.DiffState // Previous line values by input
+	counter    *valueCounter                  // Count field values instead of printing lines if set
+	strict     *parseChecker                  // Record invalid lines if set
+	replay     *replayer                      // Pace lines by their times if set
+	headerMu   sync.Mutex
+	lineHeader uint32 // Write the header of the fields of the first printed line before it, if fields depend on lines
+
+	head    uint64 // Stop after printing this number of lines if set
+	written uint64 // Number of lines written, updated atomically
+	stop    func() // Stops all inputs
+	broken  uint32 // Output is a broken pipe, updated atomically
+}
+
+// print formats line and writes it to out, then returns what happened to the line.
+func (p *printer) print(src *lineStats, line []byte, buff *bytes.Buffer, out io.Writer) nice.Result {
+	srcLine := atomic.LoadUint64(&src.read) + 1 // Line is recorded after printed
+	if p.strict != nil {
+		p.strict.check(p, src.name, srcLine, line)
+	}
+	if p.replay != nil {
+		p.replay.wait(p, src, line)
+	}
+	if p.counter != nil {
+		return p.count(line)
+	}
+	if p.context > 0 {
+		return p.contextOf(src).print(p, src, srcLine, line, buff, out)
+	}
+	return p.format(src, line, buff, p.emitFunc(src.name, srcLine, out))
+}
+
+// format formats line of input src, compared to the previous line of src in diff mode.
+func (p *printer) format(src *lineStats, line []byte, buff *bytes.Buffer, emit nice.EmitFunc) nice.Result {
+	if !p.diff {
+		return p.f.FormatFunc(line, buff, emit)
+	}
+	p.diffsMu.Lock()
+	diff, ok := p.diffs[src]
+	if !ok {
+		if p.diffs == nil {
+			p.diffs = make(map[*lineStats]*nice.DiffState)
+		}
+		diff = &nice.DiffState{}
+		p.diffs[src] = diff
+	}
+	p.diffsMu.Unlock()
+	return p.f.FormatDiff(line, buff, diff, emit)
+}
+
+// emitFunc returns the function emitting formatted lines at srcLine of input name to out.
+func (p *printer) emitFunc(name string, srcLine uint64, out io.Writer) nice.EmitFunc {
+	return func(formatted []byte, jsonLine gjson.Result) bool {
+		if p.affix != nil {
+			formatted = p.affix.wrap(name, formatted)
+		}
+		if p.numbers == nil {
+			return p.emit(jsonLine, formatted, nil, out)
+		}
+		return p.numbers.emit(name, srcLine, func(prefix []byte) bool {
+			return p.emit(jsonLine, formatted, prefix, out)
+		})
+	}
+}
 
-		if idx < len(outColors) { // Has color format
-			buff.WriteString(outColors[idx].Sprintf("%s\t", val))
+// contextOf returns the context lines of input src.
+func (p *printer) contextOf(src *lineStats) *contextLines {
+	p.contextsMu.Lock()
+	defer p.contextsMu.Unlock()
+	c, ok := p.contexts[src]
+	if !ok {
+		if p.contexts == nil {
+			p.contexts = make(map[*lineStats]*contextLines)
+		}
+		c = newContextLines(p.context)
+		p.contexts[src] = c
+	}
+	return c
+}
+
+// count counts the field value of line if it passes the filters.
+func (p *printer) count(line []byte) nice.Result {
+	jsonLine, valid := p.f.Parse(line)
+	if !valid {
+		return nice.Invalid
+	}
+	if !p.f.Keep(jsonLine) {
+		return nice.Filtered
+	}
+	jsField := jsonLine.Get(p.counter.field)
+	if !jsField.Exists() {
+		return nice.Skipped
+	}
+	p.counter.add(nice.FieldValue(jsField))
+	return nice.Printed
+}
+
+// emit writes the formatted line to out, preceded by its group header if grouped.
+// It reports false if the line is dropped as a repeat of the last line.
+// The line is written with prefix (e.g. line number), which is not compared by dedup.
+func (p *printer) emit(jsonLine gjson.Result, line, prefix []byte, out io.Writer) bool {
+	if atomic.LoadUint32(&p.lineHeader) == 1 {
+		p.printLineHeader(jsonLine, out)
+	}
+	if p.uniq != nil {
+		if key, ok := p.uniq.key(jsonLine); ok {
+			if p.uniq.keepLast {
+				p.uniq.hold(key, jsonLine, line, prefix, p.emitHeld(out))
+				return true
+			}
+			if !p.uniq.first(key) {
+				return false
+			}
+		}
+	}
+	return p.emitGrouped(jsonLine, line, prefix, out)
+}
+
+// emitHeld returns function emitting lines held by --uniq-keep last to out, by the same
+// grouping and dedup stages as other lines.
+func (p *printer) emitHeld(out io.Writer) func(jsonLine gjson.Result, line, prefix []byte) {
+	return func(jsonLine gjson.Result, line, prefix []byte) {
+		p.emitGrouped(jsonLine, line, prefix, out)
+	}
+}
+
+func (p *printer) emitGrouped(jsonLine gjson.Result, line, prefix []byte, out io.Writer) bool {
+	if p.group != nil {
+		return p.group.write(jsonLine, func(header []byte) {
+			p.writeUncounted(header, out)
+		}, func() bool {
+			return p.emitLine(jsonLine, line, prefix, out)
+		})
+	}
+	return p.emitLine(jsonLine, line, prefix, out)
+}
+
+func (p *printer) emitLine(jsonLine gjson.Result, line, prefix []byte, out io.Writer) bool {
+	key := ""
+	if p.dedup != nil {
+		key = p.dedup.lineKey(jsonLine, line)
+	}
+	if len(prefix) > 0 {
+		line = append(prefix, line...)
+	}
+	if p.dedup == nil {
+		p.write(line, out)
+		return true
+	}
+	return !p.dedup.write(key, line, p.writeTo(out))
+}
+
+// flush writes the lines held until all inputs are done to out:
+// the last table rows, the lines of --uniq-keep last, then the last --dedup line.
+func (p *printer) flush(out io.Writer) {
+	p.f.Flush(p.emitFunc("", 0, out)) // Last table rows, numbered like other lines
+	if p.uniq != nil {
+		p.uniq.flush(p.emitHeld(out))
+	}
+	if p.dedup != nil {
+		p.dedup.flush(p.writeTo(out))
+	}
+}
+
+// writeTo returns function writing formatted lines to out.
+func (p *printer) writeTo(out io.Writer) func([]byte) {
+	return func(line []byte) {
+		p.write(line, out)
+	}
+}
+
+// write writes the formatted line to out.
+// Lines after the head limit reached are dropped as inputs may still be stopping.
+func (p *printer) write(line []byte, out io.Writer) {
+	n := atomic.AddUint64(&p.written, 1)
+	if p.head > 0 {
+		if n > p.head {
+			return
+		}
+		if n == p.head {
+			defer func() {
+				logInfof("nice: %d lines printed. Start exiting", p.head)
+				p.stop()
+			}()
+		}
+	}
+	if atomic.LoadUint32(&p.broken) == 1 {
+		return
+	}
+	// Write line at once so it's not interleaved with other inputs
+	if _, err := out.Write(line); err != nil {
+		p.writeFailed(err, line)
+	}
+}
+
+// writeFailed handles error of writing line to output. If output is a broken pipe,
+// writing is stopped and all inputs are stopped to exit quietly.
+func (p *printer) writeFailed(err error, line []byte) {
+	if !isBrokenPipe(err) {
+		if line != nil {
+			log.Printf("nice: failed to write to output: %s. Log: %s", err, line)
 		} else {
-			buff.WriteString(val + "\t")
+			log.Printf("nice: failed to flush output: %v", err)
 		}
+		return
+	}
+	if atomic.CompareAndSwapUint32(&p.broken, 0, 1) {
+		logInfof("nice: output closed. Start exiting")
+		p.stop()
 	}
+}
 
-	if buff.Len() == 0 {
+// matched reports whether any line was printed, or counted by --count.
+func (p *printer) matched() bool {
+	if p.counter != nil {
+		return p.counter.len() > 0
+	}
+	return atomic.LoadUint64(&p.written) > 0
+}
+
+// printHeader writes the output field names to out. If fields depend on lines, they're
+// written by printLineHeader before the first printed line instead.
+func (p *printer) printHeader(out io.Writer) {
+	if p.counter != nil {
 		return
 	}
-	buff.WriteString("\n")
-	if _, err := fmt.Fprintf(out, "%s", buff.Bytes()); err != nil {
-		log.Printf("nice: failed to write to output: %s. Log: %s", err, buff.Bytes())
+	if p.f.DynamicFields() {
+		p.lineHeader = 1
+		return
+	}
+	if header := p.f.Header(); header != nil {
+		p.writeUncounted(header, out)
 	}
 }
 
-func getColorFormat(inStr string) []*color.Color {
-	if len(inStr) == 0 || strings.TrimSpace(inStr) == "" {
-		return nil
+// printLineHeader writes the output field names of jsonLine to out, if it's the first parsed line printed.
+func (p *printer) printLineHeader(jsonLine gjson.Result, out io.Writer) {
+	if !jsonLine.Exists() {
+		return // Passthrough line
+	}
+	p.headerMu.Lock()
+	defer p.headerMu.Unlock()
+	if atomic.LoadUint32(&p.lineHeader) == 0 {
+		return
 	}
+	if header := p.f.LineHeader(jsonLine); header != nil {
+		p.writeUncounted(header, out)
+	}
+	atomic.StoreUint32(&p.lineHeader, 0) // After the header is written so no line is written before it
+}
 
-	colors := strings.Split(inStr, ",")
-	var outColors []*color.Color
-	for _, c := range colors {
-		switch strings.ToLower(strings.TrimSpace(c)) {
-		case "black":
-			outColors = append(outColors, color.New(color.FgBlack))
-		case "red":
-			outColors = append(outColors, color.New(color.FgRed))
-		case "green":
-			outColors = append(outColors, color.New(color.FgGreen))
-		case "yellow":
-			outColors = append(outColors, color.New(color.FgYellow))
-		case "blue":
-			outColors = append(outColors, color.New(color.FgBlue))
-		case "magenta":
-			outColors = append(outColors, color.New(color.FgMagenta))
-		case "cyan":
-			outColors = append(outColors, color.New(color.FgCyan))
-		case "white":
-			outColors = append(outColors, color.New(color.FgWhite))
-		default:
-			outColors = append(outColors, color.New(color.Reset))
-		}
+// writeUncounted writes b (e.g. headers) to out without counting it as a line by --head.
+// Nothing is written once the output is broken or the head limit reached.
+func (p *printer) writeUncounted(b []byte, out io.Writer) {
+	if atomic.LoadUint32(&p.broken) == 1 || (p.head > 0 && atomic.LoadUint64(&p.written) >= p.head) {
+		return
+	}
+	if _, err := out.Write(b); err != nil {
+		p.writeFailed(err, b)
 	}
+}
+
+// multiFlag is a flag which can be repeated multiple times.
+type multiFlag []string
 
-	return outColors
+func (f *multiFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *multiFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// formatterInput returns the formatter input format of input. CSV and TSV rows are converted
+// to JSON lines before formatting.
+func formatterInput(input string) string {
+	if input == inputCSV || input == inputTSV {
+		return nice.InputJSON
+	}
+	return input
+}
+
+// isTerminal reports whether f is a terminal (character device).
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return (fi.Mode() & os.ModeCharDevice) != 0
+}
+
+// logInfof logs informational messages, which are suppressed in quiet mode.
+func logInfof(format string, v ...interface{}) {
+	if fQuiet {
+		return
+	}
+	log.Printf(format, v...)
+}
+
+// isFlagSet reports whether the flag with name was set in command line.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
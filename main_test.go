@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lnquy/nice/pkg/nice"
+)
+
+// newTestPrinter returns a printer of msg fields.
+func newTestPrinter(t *testing.T) *printer {
+	t.Helper()
+	f, err := nice.NewFormatter(nice.Options{Fields: "msg"})
+	if err != nil {
+		t.Fatalf("NewFormatter() error: %v", err)
+	}
+	return &printer{f: f, stop: func() {}}
+}
+
+// writeTestFile writes lines to file name in a temporary directory and returns its path.
+func writeTestFile(t *testing.T, dir, name string, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	return path
+}
+
+// Run with -race: stdin and merged files are replayed by their own clocks concurrently.
+func TestReplayMergedWithStdin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nice")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	files := []string{
+		writeTestFile(t, dir, "a.log", `{"time":"2020-01-01T00:00:00.000Z","msg":"a1"}`, `{"time":"2020-01-01T00:00:00.002Z","msg":"a2"}`),
+		writeTestFile(t, dir, "b.log", `{"time":"2020-01-01T00:00:00.001Z","msg":"b1"}`, `{"time":"2020-01-01T00:00:00.003Z","msg":"b2"}`),
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe() error: %v", err)
+	}
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+	go func() {
+		for _, ms := range []string{"000", "001", "002", "003"} {
+			_, _ = w.WriteString(`{"time":"2020-01-01T00:00:00.` + ms + `Z","msg":"s` + ms + `"}` + "\n")
+		}
+		_ = w.Close()
+	}()
+
+	ctx := context.Background()
+	p := newTestPrinter(t)
+	p.replay = newReplayer("time", 1, ctx.Done())
+	buff := &bytes.Buffer{}
+	out := newSyncWriter(buff)
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go pipeStdin(ctx, &wg, p, out)
+	go mergeFiles(ctx, &wg, files, "time", p, out)
+	wg.Wait()
+
+	var merged []string
+	for _, line := range strings.Split(strings.TrimSpace(buff.String()), "\n") {
+		if !strings.HasPrefix(line, "s") {
+			merged = append(merged, line)
+		}
+	}
+	if got, want := strings.Join(merged, ","), "a1,b1,a2,b2"; got != want {
+		t.Errorf("merged lines = %v, want %v", got, want)
+	}
+	lines := strings.Fields(buff.String())
+	sort.Strings(lines)
+	if got, want := strings.Join(lines, ","), "a1,a2,b1,b2,s000,s001,s002,s003"; got != want {
+		t.Errorf("lines = %v, want %v", got, want)
+	}
+}
+
+func TestScanFileTailSaveOffset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nice")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeTestFile(t, dir, "app.log", "1", "2", "3", "4")
+
+	tail, saveOffset := fTail, fSaveOffset
+	fTail, fSaveOffset = 2, true
+	defer func() { fTail, fSaveOffset = tail, saveOffset }()
+	scanLast := func() string {
+		var lines []string
+		scanFile(context.Background(), path, func(line []byte) {
+			lines = append(lines, string(line))
+		})
+		return strings.Join(lines, ",")
+	}
+
+	// No offset saved yet on the first run, --tail applies
+	if got, want := scanLast(), "3,4"; got != want {
+		t.Errorf("first run lines = %v, want %v", got, want)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() error: %v", err)
+	}
+	_, _ = f.WriteString("5\n")
+	_ = f.Close()
+	// Resumed from the saved offset
+	if got, want := scanLast(), "5"; got != want {
+		t.Errorf("second run lines = %v, want %v", got, want)
+	}
+}
+
+func TestUniqKeepLastDedup(t *testing.T) {
+	lines := []string{
+		`{"user":"a","msg":"hi"}`,
+		`{"user":"b","msg":"hi"}`,
+		`{"user":"a","msg":"hi"}`,
+		`{"user":"c","msg":"bye"}`,
+	}
+	for _, keepLast := range []bool{false, true} {
+		p := newTestPrinter(t)
+		p.uniq = newUniqFilter("user", keepLast, 0)
+		p.dedup = newDeduper("", false)
+		out := &bytes.Buffer{}
+		src := &lineStats{name: "test"}
+		for _, line := range lines {
+			p.print(src, []byte(line), &bytes.Buffer{}, out)
+		}
+		p.flush(out)
+		// Lines of users a and b are the same so they're collapsed by --dedup either way
+		if got, want := out.String(), "hi\nbye\n"; got != want {
+			t.Errorf("keepLast %v: output = %q, want %q", keepLast, got, want)
+		}
+	}
+}
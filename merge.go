@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/lnquy/nice/pkg/nice"
+)
+
+// mergeFiles reads multiple files concurrently and prints their lines in order of
+// the timeField, by k-way merging the per-file streams.
+// Lines having no parsable time keep the time of the previous line in the same file,
+// so the original order of each file is preserved.
+func mergeFiles(ctx context.Context, wg *sync.WaitGroup, files []string, timeField string, p *printer, out io.Writer) {
+	defer wg.Done()
+
+	h := make(mergeHeap, 0, len(files))
+	sources := make([]*mergeSource, 0, len(files))
+	for _, filepath := range files {
+		ch := make(chan []byte, 64)
+		go func(filepath string) {
+			defer close(ch)
+			scanFile(ctx, filepath, func(line []byte) {
+				select {
+				case ch <- append([]byte(nil), line...):
+				case <-ctx.Done():
+				}
+			})
+		}(filepath)
+		sources = append(sources, &mergeSource{lines: ch, stats: inputStats.add(filepath)})
+	}
+
+	if p.replay != nil {
+		// Pace by the times lines are merged by, so pacing follows the merged order
+		stats := make([]*lineStats, 0, len(sources))
+		for _, s := range sources {
+			stats = append(stats, s.stats)
+		}
+		p.replay.share(stats, timeField)
+	}
+	for _, s := range sources {
+		if s.next(p, timeField) {
+			h = append(h, s)
+		}
+	}
+	heap.Init(&h)
+
+	buff := bytes.NewBuffer(make([]byte, 0, 1024))
+	for h.Len() > 0 {
+		s := h[0]
+		buff.Reset()
+		s.stats.record(p.print(s.stats, s.line, buff, out))
+
+		if s.next(p, timeField) {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+}
+
+// mergeSource is the stream of lines from a file in the merge heap.
+type mergeSource struct {
+	lines <-chan []byte
+	line  []byte    // Current head line
+	time  time.Time // Time of the head line
+	stats *lineStats
+}
+
+// next advances to the next line of the source, reports false when the source is drained.
+func (s *mergeSource) next(p *printer, timeField string) bool {
+	line, ok := <-s.lines
+	if !ok {
+		return false
+	}
+	s.line = line
+	jsonLine, _ := p.f.Parse(line)
+	if t, ok := nice.ParseTime(jsonLine.Get(timeField)); ok {
+		s.time = t
+	}
+	return true
+}
+
+// mergeHeap is a min heap of merge sources by their head line time.
+type mergeHeap []*mergeSource
+
+func (h mergeHeap) Len() int            { return len(h) }
+func (h mergeHeap) Less(i, j int) bool  { return h[i].time.Before(h[j].time) }
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(*mergeSource)) }
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	s := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return s
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"regexp"
+)
+
+// multilineStart matches the first line of multiline records if set by --multiline-start.
+var multilineStart *regexp.Regexp
+
+// lineJoiner joins physical lines into records, each record starts with a line matching start.
+// Lines before the first start line are joined into a record too.
+type lineJoiner struct {
+	start   *regexp.Regexp
+	maxSize int // Records are split once longer than this
+	fn      func(record []byte)
+	record  []byte
+	started bool
+}
+
+func newLineJoiner(start *regexp.Regexp, maxSize int, fn func(record []byte)) *lineJoiner {
+	return &lineJoiner{start: start, maxSize: maxSize, fn: fn}
+}
+
+// add adds line to the current record, or flushes the record if line starts a new one.
+func (j *lineJoiner) add(line []byte) {
+	if j.started && (j.start.Match(line) || len(j.record)+1+len(line) > j.maxSize) {
+		j.flush()
+	}
+	if j.started {
+		j.record = append(j.record, '\n')
+	}
+	j.record = append(j.record, line...)
+	j.started = true
+}
+
+// flush calls fn with the current record, if any.
+func (j *lineJoiner) flush() {
+	if !j.started {
+		return
+	}
+	j.fn(j.record)
+	j.record = j.record[:0]
+	j.started = false
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// lineNumberer prefixes output lines by their number, like grep -n.
+// Printed lines are numbered in output order across all inputs.
+type lineNumberer struct {
+	source   bool // Number by line of input instead of printed lines
+	showName bool // Prefix input name too, e.g. with multiple inputs
+
+	mu      sync.Mutex
+	printed uint64
+}
+
+func newLineNumberer(source, showName bool) *lineNumberer {
+	return &lineNumberer{source: source, showName: showName}
+}
+
+// emit calls fn with the prefix of the formatted line from input name at srcLine.
+// Printed line number is only taken if fn reports the line is written.
+func (n *lineNumberer) emit(name string, srcLine uint64, fn func(prefix []byte) bool) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	num := srcLine
+	if !n.source {
+		num = n.printed + 1
+	}
+	if !fn(n.prefix(name, num)) {
+		return false
+	}
+	n.printed++
+	return true
+}
+
+func (n *lineNumberer) prefix(name string, num uint64) []byte {
+	var prefix string
+	if n.showName {
+		prefix = color.MagentaString(name) + ":"
+	}
+	return []byte(prefix + color.GreenString(strconv.FormatUint(num, 10)) + ":")
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// offsetFileSuffix is the suffix of the state file saving the read offset of an input file.
+const offsetFileSuffix = ".niceoffset"
+
+// offsetTracker tracks the byte offset of the scanned lines of a file,
+// so reading can be resumed from there.
+type offsetTracker struct {
+	offset int64 // Updated atomically
+}
+
+// add counts n bytes of scanned lines.
+func (t *offsetTracker) add(n int) {
+	atomic.AddInt64(&t.offset, int64(n))
+}
+
+// reset resets the offset to the beginning of file, e.g. when it's rotated or truncated.
+func (t *offsetTracker) reset() {
+	atomic.StoreInt64(&t.offset, 0)
+}
+
+// seekOffset moves the offset of f to offset, or to the beginning if the file
+// is shorter than offset as it was truncated. It returns the new offset.
+func seekOffset(f *os.File, offset int64) (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if fi.Size() < offset {
+		logInfof("nice: [%v]: file truncated below offset %d. Read from beginning", f.Name(), offset)
+		offset = 0
+	}
+	return f.Seek(offset, io.SeekStart)
+}
+
+// loadOffset returns the saved offset of file at path and reports whether it's found.
+func loadOffset(path string) (int64, bool, error) {
+	data, err := ioutil.ReadFile(path + offsetFileSuffix)
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, false, fmt.Errorf("invalid offset %q in %s", data, path+offsetFileSuffix)
+	}
+	return offset, true, nil
+}
+
+// saveOffset saves offset of file at path, to be resumed by loadOffset.
+func saveOffset(path string, offset int64) error {
+	return ioutil.WriteFile(path+offsetFileSuffix, []byte(strconv.FormatInt(offset, 10)+"\n"), 0644)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// outputDialTimeout is the timeout of connecting to a remote output.
+const outputDialTimeout = 5 * time.Second
+
+// openOutput opens the output target: stdout if empty, local syslog if syslog://,
+// a socket if tcp://host:port, udp://host:port or unix:///path/to/socket, or a file otherwise.
+// It reports whether the output takes each write as a message (syslog, udp),
+// so lines must not be batched.
+func openOutput(target string, appendFile bool) (io.WriteCloser, bool, error) {
+	switch {
+	case target == "":
+		return os.Stdout, false, nil
+	case target == "syslog://":
+		w, err := openSyslog("nice")
+		return w, true, err
+	case strings.HasPrefix(target, "tcp://"), strings.HasPrefix(target, "udp://"), strings.HasPrefix(target, "unix://"):
+		idx := strings.Index(target, "://")
+		w, err := dialOutput(target[:idx], target[idx+3:])
+		return w, target[:idx] == "udp", err
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendFile {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(target, flags, 0644)
+	return f, false, err
+}
+
+// netWriter writes to a socket, reconnecting when the connection dropped.
+// It's not safe for concurrent use.
+type netWriter struct {
+	network string
+	addr    string
+	conn    net.Conn
+}
+
+func dialOutput(network, addr string) (*netWriter, error) {
+	w := &netWriter{network: network, addr: addr}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *netWriter) connect() error {
+	conn, err := net.DialTimeout(w.network, w.addr, outputDialTimeout)
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+	return nil
+}
+
+// Write writes p to the connection. If writing failed, it reconnects and retries once,
+// the next write reconnects again if that failed too.
+func (w *netWriter) Write(p []byte) (int, error) {
+	if w.conn == nil {
+		if err := w.connect(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.conn.Write(p)
+	if err == nil {
+		return n, nil
+	}
+
+	log.Printf("nice: [%v://%v]: failed to write: %v. Reconnecting", w.network, w.addr, err)
+	w.conn.Close()
+	w.conn = nil
+	if err := w.connect(); err != nil {
+		return 0, err
+	}
+	return w.conn.Write(p)
+}
+
+func (w *netWriter) Close() error {
+	if w.conn == nil {
+		return nil
+	}
+	return w.conn.Close()
+}
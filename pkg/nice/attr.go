@@ -0,0 +1,61 @@
+package nice
+
+import "github.com/tidwall/gjson"
+
+// defaultAttrField is the field of OpenTelemetry log attributes.
+const defaultAttrField = "attributes"
+
+// anyValueKeys are the keys of OpenTelemetry AnyValue objects wrapping attribute values.
+var anyValueKeys = []string{"stringValue", "intValue", "doubleValue", "boolValue", "bytesValue", "arrayValue", "kvlistValue"}
+
+// parseAttrFields parses attribute keys in form of key[:alias][#color], separated by comma (,),
+// to output fields looked up in the attrField of lines. Field indexes start from offset.
+func parseAttrFields(attrs, attrField string, offset int) []outField {
+	fields := parseFields(attrs)
+	for idx := range fields {
+		fields[idx].attr = fields[idx].path
+		fields[idx].path = attrField
+		fields[idx].index += offset
+		fields[idx].wildcard = false // Keys are matched as is
+	}
+	return fields
+}
+
+// get returns the value of field in jsonLine.
+func (field outField) get(jsonLine gjson.Result) gjson.Result {
+	if field.attr == "" {
+		return getPath(jsonLine, field.path)
+	}
+	return lookupAttr(jsonLine.Get(field.path), field.attr)
+}
+
+// lookupAttr returns the value of attribute key in attrs, which is either an array of
+// key/value pairs as OpenTelemetry exports them, or an object keyed by attribute names.
+// AnyValue wrappers (e.g. {"stringValue":"GET"}) are unwrapped.
+func lookupAttr(attrs gjson.Result, key string) gjson.Result {
+	var val gjson.Result
+	switch {
+	case attrs.IsArray():
+		attrs.ForEach(func(_, attr gjson.Result) bool {
+			if attr.Get("key").String() != key {
+				return true
+			}
+			val = attr.Get("value")
+			return false
+		})
+	case attrs.IsObject():
+		// Attribute names are usually dotted (e.g. http.method) but may also be nested
+		if val = attrs.Get(escapePath(key)); !val.Exists() {
+			val = attrs.Get(key)
+		}
+	}
+	if !val.IsObject() {
+		return val
+	}
+	for _, k := range anyValueKeys {
+		if v := val.Get(k); v.Exists() {
+			return v
+		}
+	}
+	return val
+}
@@ -0,0 +1,47 @@
+package nice
+
+import (
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/tidwall/gjson"
+)
+
+// autoColors are rotated over auto fields if no colors are configured.
+var autoColors = []*color.Color{
+	color.New(color.FgCyan),
+	color.New(color.FgGreen),
+	color.New(color.FgYellow),
+	color.New(color.FgBlue),
+	color.New(color.FgMagenta),
+}
+
+// autoFields are the output fields taken from the top-level keys of the first non-empty JSON object line,
+// so all lines are printed in the same stable columns.
+// It's safe for concurrent use.
+type autoFields struct {
+	mu       sync.Mutex
+	colorize bool // Assign rotating colors to fields
+	fields   []outField
+}
+
+// get returns the auto fields, collecting them from jsonLine if they're not collected yet.
+// The returned fields must not be modified.
+func (a *autoFields) get(jsonLine gjson.Result) []outField {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.fields != nil || !jsonLine.IsObject() {
+		return a.fields
+	}
+	var fields []outField
+	jsonLine.ForEach(func(key, _ gjson.Result) bool {
+		field := outField{path: escapePath(key.Str), alias: key.Str, index: len(fields)}
+		if a.colorize {
+			field.color = autoColors[len(fields)%len(autoColors)]
+		}
+		fields = append(fields, field)
+		return true
+	})
+	a.fields = fields // Still nil for empty objects, so fields are collected from the next line
+	return a.fields
+}
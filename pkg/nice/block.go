@@ -0,0 +1,73 @@
+package nice
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/tidwall/gjson"
+)
+
+// formatBlock writes the output fields of jsonLine to buff as a block of label: value lines,
+// labeled by field aliases, followed by a blank line.
+// Missing fields are skipped, or replaced by the missing placeholder if configured.
+// Nothing is written if none of the fields has value.
+func (f *Formatter) formatBlock(jsonLine gjson.Result, buff *bytes.Buffer, diff *DiffState) {
+	type blockLine struct {
+		label string
+		val   string
+	}
+	lineColor := f.lineColor(jsonLine)
+	var lines []blockLine
+	labelWidth := 0
+	hasValue := false
+	for _, field := range f.lineFields(jsonLine) {
+		jsField := field.get(jsonLine)
+		val, pretty := f.prettyValue(jsField)
+		if !pretty {
+			val = f.value(field, jsField)
+			if f.maxWidths != nil {
+				val = f.maxWidths.truncate(field, val)
+			}
+			val = highlight(f.highlight, val)
+		}
+		if strings.TrimSpace(val) == "" {
+			diff.compare(field.alias, "")
+			if !f.hasMissing {
+				continue
+			}
+			val = f.missing
+		} else {
+			hasValue = true
+			dc := diff.compare(field.alias, val)
+			if !pretty && lineColor != nil {
+				val = diffColor(dc, val, lineColor.Sprint(val))
+			} else if c := f.fieldColor(field); !pretty && c != nil {
+				val = diffColor(dc, val, c.Sprint(val))
+			} else if !pretty && f.valueColor != nil {
+				val = diffColor(dc, val, f.valueColor.Sprint(val))
+			} else if !pretty {
+				val = diffColor(dc, val, val)
+			}
+		}
+		lines = append(lines, blockLine{label: field.alias, val: val})
+		if w := displayWidth(field.alias); w > labelWidth {
+			labelWidth = w
+		}
+	}
+	if !hasValue {
+		return
+	}
+
+	labelColor := f.keyColor
+	if labelColor == nil {
+		labelColor = color.New(color.Bold)
+	}
+	for _, line := range lines {
+		buff.WriteString(labelColor.Sprint(line.label + ":"))
+		buff.WriteString(strings.Repeat(" ", labelWidth-displayWidth(line.label)+1))
+		// Indent multiline values (e.g. pretty JSON or stack traces) under the label
+		buff.WriteString(strings.Replace(line.val, "\n", "\n  ", -1))
+		buff.WriteString("\n")
+	}
+}
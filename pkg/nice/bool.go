@@ -0,0 +1,32 @@
+package nice
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// parseBoolFormat parses the symbols of boolean values in form of true=S,false=S,
+// e.g. true=✓,false=✗. Either value can be omitted to keep it printed as is.
+func parseBoolFormat(spec string) (map[gjson.Type]string, error) {
+	symbols := make(map[gjson.Type]string)
+	for _, part := range strings.Split(spec, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		idx := strings.Index(part, "=")
+		if idx < 0 {
+			return nil, fmt.Errorf("invalid symbol %q, expecting true=S or false=S", part)
+		}
+		switch name := strings.TrimSpace(part[:idx]); name {
+		case "true":
+			symbols[gjson.True] = part[idx+1:]
+		case "false":
+			symbols[gjson.False] = part[idx+1:]
+		default:
+			return nil, fmt.Errorf("invalid value %q, expecting true or false", name)
+		}
+	}
+	return symbols, nil
+}
@@ -0,0 +1,270 @@
+package nice
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/tidwall/gjson"
+)
+
+func getColorFormat(inStr string) []*color.Color {
+	if len(inStr) == 0 || strings.TrimSpace(inStr) == "" {
+		return nil
+	}
+
+	colors := strings.Split(inStr, ",")
+	var outColors []*color.Color
+	for _, c := range colors {
+		outColors = append(outColors, parseColor(strings.ToLower(strings.TrimSpace(c))))
+	}
+
+	return outColors
+}
+
+// parseColor parses a color token in form of fg, bgcolor (e.g. bgred) or fg:bg (e.g. black:bgwhite),
+// optionally with style modifiers joined by plus (+), e.g. red+bold+underline.
+// Unknown tokens fall back to reset color.
+func parseColor(token string) *color.Color {
+	c, ok := lookupColor(token)
+	if !ok {
+		return color.New(color.Reset)
+	}
+	return c
+}
+
+// lookupColor parses a color token like parseColor and reports whether the token is valid.
+func lookupColor(token string) (*color.Color, bool) {
+	var attrs []color.Attribute
+	for _, part := range strings.Split(token, "+") {
+		if style, ok := styleAttribute(part); ok {
+			attrs = append(attrs, style)
+			continue
+		}
+		colorAttrs, ok := parseColorPair(part)
+		if !ok {
+			return nil, false
+		}
+		attrs = append(attrs, colorAttrs...)
+	}
+	return color.New(attrs...), true
+}
+
+// parseColorPair parses color in form of fg, bgcolor or fg:bg to color attributes.
+// Colors are names, xterm 256 palette codes (e.g. 256:214) or hex RGB (e.g. #ff8800).
+func parseColorPair(token string) ([]color.Attribute, bool) {
+	var parts []string
+	for _, part := range strings.Split(token, ":") {
+		// Rejoin the palette code of 256:N
+		if n := len(parts); n > 0 && (parts[n-1] == "256" || parts[n-1] == "bg256") {
+			parts[n-1] += ":" + part
+			continue
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) > 2 {
+		return nil, false
+	}
+
+	var attrs []color.Attribute
+	for idx, part := range parts {
+		// Second part of fg:bg is always background, bg prefix is optional there
+		isBg := idx == 1 || strings.HasPrefix(part, "bg")
+		colorAttrs, ok := colorAttributes(strings.TrimPrefix(part, "bg"), isBg)
+		if !ok {
+			return nil, false
+		}
+		attrs = append(attrs, colorAttrs...)
+	}
+	return attrs, true
+}
+
+// colorAttributes returns the foreground or background attributes of color name,
+// 256 palette code or hex RGB. Hex RGB falls back to the nearest named color
+// if the terminal doesn't support true color.
+func colorAttributes(name string, isBg bool) ([]color.Attribute, bool) {
+	offset := color.Attribute(0)
+	if isBg {
+		offset = color.BgBlack - color.FgBlack
+	}
+	switch {
+	case strings.HasPrefix(name, "256:"):
+		code, err := strconv.Atoi(name[4:])
+		if err != nil || code < 0 || code > 255 {
+			return nil, false
+		}
+		// ESC[38;5;Nm or ESC[48;5;Nm
+		return []color.Attribute{extendedColor + offset, 5, color.Attribute(code)}, true
+	case strings.HasPrefix(name, "#"):
+		rgb, err := strconv.ParseUint(name[1:], 16, 32)
+		if err != nil || len(name) != 7 {
+			return nil, false
+		}
+		r, g, b := int(rgb>>16), int(rgb>>8&0xff), int(rgb&0xff)
+		if !trueColor {
+			return []color.Attribute{nearestColor(r, g, b) + offset}, true
+		}
+		// ESC[38;2;R;G;Bm or ESC[48;2;R;G;Bm
+		return []color.Attribute{extendedColor + offset, 2, color.Attribute(r), color.Attribute(g), color.Attribute(b)}, true
+	}
+	attr, ok := colorAttribute(name)
+	if !ok {
+		return nil, false
+	}
+	return []color.Attribute{attr + offset}, true
+}
+
+// extendedColor is the SGR code of extended foreground colors, followed by 5;N for 256 palette
+// or 2;R;G;B for true color. Background is 48.
+const extendedColor color.Attribute = 38
+
+// trueColor reports whether the terminal supports 24-bit colors, as advertised by COLORTERM.
+var trueColor = isTrueColor(os.Getenv("COLORTERM"))
+
+func isTrueColor(colorTerm string) bool {
+	colorTerm = strings.ToLower(colorTerm)
+	return colorTerm == "truecolor" || colorTerm == "24bit"
+}
+
+// namedColorsRGB are the RGB values of named colors in the xterm default palette.
+var namedColorsRGB = []struct {
+	attr    color.Attribute
+	r, g, b int
+}{
+	{color.FgBlack, 0, 0, 0},
+	{color.FgRed, 205, 0, 0},
+	{color.FgGreen, 0, 205, 0},
+	{color.FgYellow, 205, 205, 0},
+	{color.FgBlue, 0, 0, 238},
+	{color.FgMagenta, 205, 0, 205},
+	{color.FgCyan, 0, 205, 205},
+	{color.FgWhite, 229, 229, 229},
+}
+
+// nearestColor returns the foreground attribute of the named color nearest to RGB.
+func nearestColor(r, g, b int) color.Attribute {
+	nearest, minDist := color.FgWhite, -1
+	for _, c := range namedColorsRGB {
+		dist := (c.r-r)*(c.r-r) + (c.g-g)*(c.g-g) + (c.b-b)*(c.b-b)
+		if minDist < 0 || dist < minDist {
+			nearest, minDist = c.attr, dist
+		}
+	}
+	return nearest
+}
+
+// styleAttribute returns the attribute of the style modifier name.
+func styleAttribute(name string) (color.Attribute, bool) {
+	switch name {
+	case "bold":
+		return color.Bold, true
+	case "faint", "dim":
+		return color.Faint, true
+	case "italic":
+		return color.Italic, true
+	case "underline":
+		return color.Underline, true
+	case "blink":
+		return color.BlinkSlow, true
+	case "reverse":
+		return color.ReverseVideo, true
+	case "strike":
+		return color.CrossedOut, true
+	default:
+		return color.Reset, false
+	}
+}
+
+// colorAttribute returns the foreground attribute of the color name.
+func colorAttribute(name string) (color.Attribute, bool) {
+	switch name {
+	case "black":
+		return color.FgBlack, true
+	case "red":
+		return color.FgRed, true
+	case "green":
+		return color.FgGreen, true
+	case "yellow":
+		return color.FgYellow, true
+	case "blue":
+		return color.FgBlue, true
+	case "magenta":
+		return color.FgMagenta, true
+	case "cyan":
+		return color.FgCyan, true
+	case "white":
+		return color.FgWhite, true
+	default:
+		return color.Reset, false
+	}
+}
+
+// valueColor colors a line when its field equals to value.
+type valueColor struct {
+	field string
+	value string
+	color *color.Color
+}
+
+// getColorMap parses color map in form of field:value=color, separated by comma (,).
+func getColorMap(inStr string) ([]*valueColor, error) {
+	if strings.TrimSpace(inStr) == "" {
+		return nil, nil
+	}
+
+	var colorMap []*valueColor
+	for _, rule := range strings.Split(inStr, ",") {
+		eqIdx := strings.LastIndex(rule, "=")
+		colonIdx := strings.Index(rule, ":")
+		if colonIdx < 1 || eqIdx < colonIdx {
+			return nil, fmt.Errorf("invalid color rule %q, expecting field:value=color", rule)
+		}
+		colors := getColorFormat(rule[eqIdx+1:])
+		if len(colors) == 0 {
+			return nil, fmt.Errorf("invalid color rule %q, missing color", rule)
+		}
+		colorMap = append(colorMap, &valueColor{
+			field: strings.TrimSpace(rule[:colonIdx]),
+			value: strings.TrimSpace(rule[colonIdx+1 : eqIdx]),
+			color: colors[0],
+		})
+	}
+	return colorMap, nil
+}
+
+// levelColors are the default colors of log levels in auto color mode.
+var levelColors = []struct{ level, color string }{
+	{"debug", "cyan"},
+	{"info", "green"},
+	{"warn", "yellow"},
+	{"warning", "yellow"},
+	{"error", "red"},
+	{"err", "red"},
+	{"fatal", "red"},
+	{"panic", "red"},
+}
+
+// getLevelColorMap returns color map coloring lines by the default colors of levelField values.
+func getLevelColorMap(levelField string) []*valueColor {
+	var colorMap []*valueColor
+	for _, lc := range levelColors {
+		colorMap = append(colorMap, &valueColor{
+			field: levelField,
+			value: lc.level,
+			color: getColorFormat(lc.color)[0],
+		})
+	}
+	return colorMap
+}
+
+// lineColor returns color of the first color map rule matched jsonLine, or nil if none matched.
+func (f *Formatter) lineColor(jsonLine gjson.Result) *color.Color {
+	for _, vc := range f.colorMap {
+		if strings.EqualFold(FieldValue(jsonLine.Get(vc.field)), vc.value) {
+			return vc.color
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package nice
+
+import (
+	"bytes"
+	"encoding/csv"
+	"log"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// formatCSV writes the output fields of jsonLine to buff as a CSV record.
+// All fields are written to keep columns stable, missing fields are empty or the
+// missing placeholder. Nothing is written if none of the fields has value.
+func (f *Formatter) formatCSV(jsonLine gjson.Result, buff *bytes.Buffer) {
+	fields := f.lineFields(jsonLine)
+	record := make([]string, 0, len(fields))
+	hasValue := false
+	for _, field := range fields {
+		val := f.value(field, field.get(jsonLine))
+		if strings.TrimSpace(val) == "" {
+			record = append(record, f.missing)
+			continue
+		}
+		record = append(record, val)
+		hasValue = true
+	}
+
+	if hasValue {
+		writeCSV(buff, record)
+	}
+}
+
+// writeCSV writes a properly quoted CSV record, without the ending newline, to buff.
+func writeCSV(buff *bytes.Buffer, record []string) {
+	w := csv.NewWriter(buff)
+	if err := w.Write(record); err != nil {
+		log.Printf("nice: failed to write CSV record: %v", err)
+		return
+	}
+	w.Flush()
+	buff.Truncate(buff.Len() - 1)
+}
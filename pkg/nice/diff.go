@@ -0,0 +1,61 @@
+package nice
+
+import "github.com/fatih/color"
+
+// Colors of output values compared to the previous line by FormatDiff
+var (
+	diffUnchanged = color.New(color.Faint)
+	diffChanged   = color.New(color.Bold)
+)
+
+// DiffState holds the output values of the previous line of a stream, so FormatDiff can
+// dim values unchanged in the next line and highlight changed ones.
+// The zero value is ready to use. It's not safe for concurrent use.
+type DiffState struct {
+	prev map[string]string // Values of the previous line by field alias, nil before the first line
+	next map[string]string // Values of the line being formatted
+}
+
+// compare records val of field alias in the current line and returns its color:
+// faint if it's the same as in the previous line, bold if it's changed or new.
+// It returns nil for the first line, or if d is nil.
+func (d *DiffState) compare(alias, val string) *color.Color {
+	if d == nil {
+		return nil
+	}
+	if d.next == nil {
+		d.next = make(map[string]string)
+	}
+	d.next[alias] = val
+	if d.prev == nil {
+		return nil
+	}
+	if prev, ok := d.prev[alias]; ok && prev == val {
+		return diffUnchanged
+	}
+	return diffChanged
+}
+
+// commit makes the values of the current line the previous ones.
+func (d *DiffState) commit() {
+	if d == nil || d.next == nil {
+		return
+	}
+	d.prev, d.next = d.next, d.prev
+	for alias := range d.next {
+		delete(d.next, alias)
+	}
+}
+
+// diffColor returns s, which is val colored by the field or line color, recolored by
+// the diff color dc: unchanged values are only dimmed, changed values are emboldened.
+func diffColor(dc *color.Color, val, s string) string {
+	switch dc {
+	case nil:
+		return s
+	case diffUnchanged:
+		return dc.Sprint(val)
+	default:
+		return dc.Sprint(s)
+	}
+}
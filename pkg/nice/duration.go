@@ -0,0 +1,41 @@
+package nice
+
+import (
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// durationUnits are the units of numeric duration field values.
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+// durationPrecision limits humanized durations to 4 significant digits.
+const durationPrecision = 10000
+
+// formatDuration formats jsField, a number of unit, as a human-readable duration
+// (e.g. 123.4ms) and reports whether jsField is numeric.
+func formatDuration(jsField gjson.Result, unit time.Duration) (string, bool) {
+	num, ok := numberValue(jsField)
+	if !ok {
+		return "", false
+	}
+	return humanizeDuration(time.Duration(num * float64(unit))), true
+}
+
+// humanizeDuration truncates d to 4 significant digits, then formats it.
+func humanizeDuration(d time.Duration) string {
+	abs := d
+	if abs < 0 {
+		abs = -abs
+	}
+	trunc := time.Duration(1)
+	for abs/trunc >= durationPrecision {
+		trunc *= 10
+	}
+	return d.Truncate(trunc).String()
+}
@@ -0,0 +1,40 @@
+package nice
+
+import (
+	"bytes"
+
+	"github.com/tidwall/gjson"
+)
+
+// explodeLine returns the elements of line having JSON array root, or the objects of line
+// having multiple JSON objects separated by spaces.
+// It returns false for other lines, including single object lines which are printed unchanged.
+func explodeLine(line []byte) ([]gjson.Result, bool) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return nil, false
+	}
+	if line[0] == '[' {
+		if !gjson.ValidBytes(line) {
+			return nil, false
+		}
+		return gjson.ParseBytes(line).Array(), true
+	}
+
+	var objs []gjson.Result
+	for rest := line; len(rest) > 0; rest = bytes.TrimSpace(rest) {
+		if rest[0] != '{' {
+			return nil, false
+		}
+		_, end, ok := findJSONObject(rest)
+		if !ok || !gjson.ValidBytes(rest[:end]) {
+			return nil, false
+		}
+		objs = append(objs, gjson.ParseBytes(rest[:end]))
+		rest = rest[end:]
+	}
+	if len(objs) < 2 {
+		return nil, false
+	}
+	return objs, true
+}
@@ -0,0 +1,232 @@
+package nice
+
+import (
+	pathpkg "path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/tidwall/gjson"
+)
+
+// outField is an output field, extracted from log line by path.
+type outField struct {
+	path     string
+	alias    string // Name of field in output, default to path
+	index    int    // Position of field in output format, used to pick positional color
+	wildcard bool   // Path contains wildcard segments to be expanded per line
+
+	color *color.Color // Inline color of field, has priority over positional color
+	attr  string       // Key of attribute to look up in path, if field is an attribute
+}
+
+// aliasRegex matches valid field aliases.
+var aliasRegex = regexp.MustCompile(`^[\w.\-]+$`)
+
+// parseFields parses output format in form of path[:alias][#color], separated by comma (,).
+func parseFields(format string) []outField {
+	var fields []outField
+	for _, f := range strings.Split(format, ",") {
+		if strings.TrimSpace(f) == "" {
+			continue
+		}
+		var fieldColor *color.Color
+		// Path can contain # of gjson queries (e.g. users.#.name), only treat valid color suffix as color.
+		// Hex colors have their own # (e.g. level##ff8800)
+		for idx := strings.LastIndex(f, "#"); idx > 0; idx = strings.LastIndex(f[:idx], "#") {
+			if c, ok := lookupColor(strings.ToLower(f[idx+1:])); ok {
+				f, fieldColor = f[:idx], c
+				break
+			}
+		}
+		field := outField{path: f, alias: f, index: len(fields), color: fieldColor}
+		// Path itself can contain colon (e.g. modifier arguments), only treat last colon as alias separator
+		if idx := strings.LastIndex(f, ":"); idx > 0 && aliasRegex.MatchString(f[idx+1:]) {
+			field.path = f[:idx]
+			field.alias = f[idx+1:]
+		}
+		field.wildcard = isWildcardPath(field.path)
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// lineFields returns the output fields of jsonLine.
+// If exclusion is configured, all top-level fields of jsonLine except the excluded
+// ones are returned in input order. Auto fields are taken from the first JSON object line.
+// Wildcard fields are expanded to one field per matched path, in document order.
+// If flattening is enabled, object fields are then expanded to their leaf values.
+func (f *Formatter) lineFields(jsonLine gjson.Result) []outField {
+	var fields []outField
+	switch {
+	case f.exclude != nil:
+		jsonLine.ForEach(func(key, _ gjson.Result) bool {
+			if !f.exclude[key.Str] {
+				fields = append(fields, outField{path: escapePath(key.Str), alias: key.Str, index: len(fields)})
+			}
+			return true
+		})
+	case f.hasWildcard:
+		fields = make([]outField, 0, len(f.fields))
+		for _, field := range f.fields {
+			if !field.wildcard {
+				fields = append(fields, field)
+				continue
+			}
+			expandWildcard(jsonLine, strings.Split(field.path, "."), func(path, alias string) {
+				fields = append(fields, outField{path: path, alias: alias, index: field.index, color: field.color})
+			})
+		}
+	case f.auto != nil:
+		fields = f.auto.get(jsonLine)
+	default:
+		fields = f.fields
+	}
+	if !f.flatten {
+		return fields
+	}
+
+	flatFields := make([]outField, 0, len(fields))
+	for _, field := range fields {
+		if field.attr != "" { // Attribute values have no paths to expand
+			flatFields = append(flatFields, field)
+			continue
+		}
+		flattenField(jsonLine.Get(field.path), field, func(flat outField) {
+			flatFields = append(flatFields, flat)
+		})
+	}
+	return flatFields
+}
+
+// flattenField calls fn with field itself if jsField is not an object, otherwise
+// with one field per leaf value of jsField, named by the dot notation path
+// from the field alias, in document order.
+// Arrays are leaf values and not expanded, empty objects are omitted.
+func flattenField(jsField gjson.Result, field outField, fn func(outField)) {
+	if !jsField.IsObject() {
+		fn(field)
+		return
+	}
+	jsField.ForEach(func(key, val gjson.Result) bool {
+		flattenField(val, outField{
+			path:  field.path + "." + escapePath(key.Str),
+			alias: field.alias + "." + key.Str,
+			index: field.index,
+			color: field.color,
+		}, fn)
+		return true
+	})
+}
+
+// isWildcardPath reports whether path has wildcard segments to be expanded,
+// e.g. user.* or **.id. Paths using gjson queries or modifiers are left to gjson.
+func isWildcardPath(path string) bool {
+	return strings.ContainsAny(path, "*?") && !strings.ContainsAny(path, "#@|\\")
+}
+
+// getPath returns the value of path in r. Besides gjson syntax, path segments can be
+// negative array indexes counted from the end, e.g. events.-1 is the last element of events.
+// Indexes out of range, including any index of an empty array, have no value.
+func getPath(r gjson.Result, path string) gjson.Result {
+	if !strings.Contains(path, "-") {
+		return r.Get(path)
+	}
+	segs := strings.Split(path, ".")
+	for idx, seg := range segs {
+		n, err := strconv.Atoi(seg)
+		if err != nil || n >= 0 || !strings.HasPrefix(seg, "-") {
+			continue
+		}
+		arr := r
+		if idx > 0 {
+			arr = r.Get(strings.Join(segs[:idx], "."))
+		}
+		if !arr.IsArray() {
+			return gjson.Result{}
+		}
+		elems := arr.Array()
+		if len(elems)+n < 0 {
+			return gjson.Result{}
+		}
+		elem := elems[len(elems)+n]
+		if idx == len(segs)-1 {
+			return elem
+		}
+		return getPath(elem, strings.Join(segs[idx+1:], "."))
+	}
+	return r.Get(path)
+}
+
+// expandWildcard calls fn with the gjson path and the dot notation name of each value
+// of r matched by the wildcard path segments, in document order.
+// A "**" segment matches zero or more levels, other segments are matched against
+// object keys or array indexes by shell pattern (* and ?).
+func expandWildcard(r gjson.Result, segs []string, fn func(path, name string)) {
+	var matches []wildcardMatch
+	walkWildcard(r, 0, segs, "", "", &matches)
+	// "**" may match deeper levels before shallower ones
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].offset < matches[j].offset
+	})
+	for _, m := range matches {
+		fn(m.path, m.name)
+	}
+}
+
+type wildcardMatch struct {
+	path   string
+	name   string
+	offset int // Position of value in the log line
+}
+
+func walkWildcard(r gjson.Result, offset int, segs []string, path, name string, matches *[]wildcardMatch) {
+	if len(segs) == 0 {
+		if r.Exists() && path != "" {
+			*matches = append(*matches, wildcardMatch{path: path, name: name, offset: offset})
+		}
+		return
+	}
+
+	seg := segs[0]
+	if seg == "**" {
+		walkWildcard(r, offset, segs[1:], path, name, matches)
+	}
+	if !r.IsObject() && !r.IsArray() {
+		return
+	}
+	idx := 0
+	r.ForEach(func(key, val gjson.Result) bool {
+		k := key.String()
+		if r.IsArray() {
+			k = strconv.Itoa(idx)
+			idx++
+		}
+		childPath, childName := escapePath(k), k
+		if path != "" {
+			childPath, childName = path+"."+childPath, name+"."+k
+		}
+
+		if seg == "**" {
+			walkWildcard(val, offset+val.Index, segs, childPath, childName, matches)
+		} else if matched, _ := pathpkg.Match(seg, k); matched {
+			walkWildcard(val, offset+val.Index, segs[1:], childPath, childName, matches)
+		}
+		return true
+	})
+}
+
+// escapePath escapes gjson path special characters in key.
+func escapePath(key string) string {
+	var sb strings.Builder
+	for _, c := range key {
+		switch c {
+		case '.', '*', '?', '|', '#', '@', '\\':
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
@@ -0,0 +1,321 @@
+package nice
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// DefaultLevels is the default severity order of log levels, from lowest to highest.
+const DefaultLevels = "trace,debug,info,warn,error,fatal,panic"
+
+// levelFilter drops lines having level lower than a minimum level.
+type levelFilter struct {
+	field    string
+	severity map[string]int
+	min      int
+}
+
+// newLevelFilter returns a filter keeping lines with level field at least minLevel,
+// based on the levels order which are separated by comma (,) from lowest to highest.
+func newLevelFilter(field, minLevel, levels string) (*levelFilter, error) {
+	f := &levelFilter{
+		field:    field,
+		severity: make(map[string]int),
+	}
+	for idx, l := range strings.Split(levels, ",") {
+		l = normalizeLevel(l)
+		if l == "" {
+			continue
+		}
+		f.severity[l] = idx
+	}
+
+	min, ok := f.severity[normalizeLevel(minLevel)]
+	if !ok {
+		return nil, fmt.Errorf("unknown level %q, available levels: %s", minLevel, levels)
+	}
+	f.min = min
+	return f, nil
+}
+
+// keep reports whether jsonLine passes the level filter.
+// Lines with missing or unknown level are always kept.
+func (f *levelFilter) keep(jsonLine gjson.Result) bool {
+	severity, ok := f.severity[normalizeLevel(FieldValue(jsonLine.Get(f.field)))]
+	if !ok {
+		return true
+	}
+	return severity >= f.min
+}
+
+func normalizeLevel(level string) string {
+	level = strings.ToLower(strings.TrimSpace(level))
+	switch level {
+	case "warning":
+		return "warn"
+	case "err":
+		return "error"
+	}
+	return level
+}
+
+// Keep reports whether jsonLine passes all configured filters, or fails them if inverted.
+func (f *Formatter) Keep(jsonLine gjson.Result) bool {
+	if f.passFilters(jsonLine) == f.invert {
+		return false
+	}
+	// Sample last so only lines passed other filters are counted
+	return f.sample == nil || f.sample.keep()
+}
+
+// passFilters reports whether jsonLine passes all configured filters.
+func (f *Formatter) passFilters(jsonLine gjson.Result) bool {
+	if f.level != nil && !f.level.keep(jsonLine) {
+		return false
+	}
+	for _, m := range f.matches {
+		if !m.keep(jsonLine) {
+			return false
+		}
+	}
+	for _, w := range f.wheres {
+		if !w.keep(jsonLine) {
+			return false
+		}
+	}
+	if f.timeRange != nil && !f.timeRange.keep(jsonLine) {
+		return false
+	}
+	return true
+}
+
+// matchFilter keeps lines having field value matched (or not matched if negated) a regex.
+type matchFilter struct {
+	field  string
+	re     *regexp.Regexp
+	negate bool
+}
+
+// newMatchFilter parses a match clause in the form of field=~regex or field!~regex.
+func newMatchFilter(clause string) (*matchFilter, error) {
+	idx := strings.Index(clause, "~")
+	if idx < 1 || (clause[idx-1] != '=' && clause[idx-1] != '!') {
+		return nil, fmt.Errorf("invalid match clause %q, expecting field=~regex or field!~regex", clause)
+	}
+	field := strings.TrimSpace(clause[:idx-1])
+	if field == "" {
+		return nil, fmt.Errorf("invalid match clause %q, missing field", clause)
+	}
+	re, err := regexp.Compile(clause[idx+1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid match clause %q: %v", clause, err)
+	}
+
+	return &matchFilter{
+		field:  field,
+		re:     re,
+		negate: clause[idx-1] == '!',
+	}, nil
+}
+
+func (f *matchFilter) keep(jsonLine gjson.Result) bool {
+	matched := f.re.MatchString(FieldValue(jsonLine.Get(f.field)))
+	return matched != f.negate
+}
+
+// timeFilter keeps lines having time field in range [since, until).
+// Zero since or until means the range is unbounded on that side.
+type timeFilter struct {
+	field   string
+	since   time.Time
+	until   time.Time
+	keepBad bool // Keep lines with missing or unparseable time
+}
+
+// newTimeFilter returns a filter keeping lines with time field between since and until.
+// Bounds can be absolute time (e.g. RFC3339) or duration relative to now (e.g. -1h).
+// onBadTime is either keep or drop.
+func newTimeFilter(field, since, until, onBadTime string, now time.Time) (*timeFilter, error) {
+	f := &timeFilter{field: field}
+	switch onBadTime {
+	case "keep":
+		f.keepBad = true
+	case "drop":
+	default:
+		return nil, fmt.Errorf("unknown bad time policy %q, expecting keep or drop", onBadTime)
+	}
+
+	var err error
+	if f.since, err = parseTimeBound(since, now); err != nil {
+		return nil, err
+	}
+	if f.until, err = parseTimeBound(until, now); err != nil {
+		return nil, err
+	}
+	if !f.since.IsZero() && !f.until.IsZero() && !f.since.Before(f.until) {
+		return nil, fmt.Errorf("since %q must be before until %q", since, until)
+	}
+	return f, nil
+}
+
+// parseTimeBound parses a time range bound. Durations are always counted back from now,
+// so 1h and -1h are both an hour ago. Empty bound returns zero time.
+func parseTimeBound(bound string, now time.Time) (time.Time, error) {
+	bound = strings.TrimSpace(bound)
+	if bound == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(bound); err == nil {
+		if d > 0 {
+			d = -d
+		}
+		return now.Add(d), nil
+	}
+	if t, ok := ParseTime(gjson.Result{Type: gjson.String, Str: bound}); ok {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expecting RFC3339 time or duration (e.g. -1h)", bound)
+}
+
+func (f *timeFilter) keep(jsonLine gjson.Result) bool {
+	t, ok := ParseTime(jsonLine.Get(f.field))
+	if !ok {
+		return f.keepBad
+	}
+	if !f.since.IsZero() && t.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && !t.Before(f.until) {
+		return false
+	}
+	return true
+}
+
+// whereOperators are the supported comparison operators, two-character ones first
+// so they are not parsed as their one-character prefixes.
+var whereOperators = []string{">=", "<=", "==", "!=", ">", "<", "="}
+
+// whereFilter keeps lines having numeric field value satisfied a comparison.
+type whereFilter struct {
+	field   string
+	op      string
+	value   float64
+	keepBad bool // Keep lines with missing or non-numeric field
+}
+
+// newWhereFilter parses a where clause in the form of field<op>number, e.g. status>=400.
+// onBadNumber is either keep or drop.
+func newWhereFilter(clause, onBadNumber string) (*whereFilter, error) {
+	f := &whereFilter{}
+	switch onBadNumber {
+	case "keep":
+		f.keepBad = true
+	case "drop":
+	default:
+		return nil, fmt.Errorf("unknown bad number policy %q, expecting keep or drop", onBadNumber)
+	}
+
+	idx := strings.IndexAny(clause, "<>=!")
+	if idx < 1 {
+		return nil, fmt.Errorf("invalid where clause %q, expecting field<op>number (e.g. status>=400)", clause)
+	}
+	for _, op := range whereOperators {
+		if strings.HasPrefix(clause[idx:], op) {
+			f.op = op
+			break
+		}
+	}
+	if f.op == "" {
+		return nil, fmt.Errorf("invalid where clause %q, unknown operator", clause)
+	}
+	f.field = strings.TrimSpace(clause[:idx])
+	if f.field == "" {
+		return nil, fmt.Errorf("invalid where clause %q, missing field", clause)
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(clause[idx+len(f.op):]), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid where clause %q, expecting number to compare", clause)
+	}
+	f.value = value
+	return f, nil
+}
+
+func (f *whereFilter) keep(jsonLine gjson.Result) bool {
+	num, ok := numberValue(jsonLine.Get(f.field))
+	if !ok {
+		return f.keepBad
+	}
+	switch f.op {
+	case ">=":
+		return num >= f.value
+	case "<=":
+		return num <= f.value
+	case "!=":
+		return num != f.value
+	case ">":
+		return num > f.value
+	case "<":
+		return num < f.value
+	default: // == and =
+		return num == f.value
+	}
+}
+
+// numberValue returns the numeric value of jsField. Numeric strings (e.g. "200") are parsed as number.
+func numberValue(jsField gjson.Result) (float64, bool) {
+	switch jsField.Type {
+	case gjson.Number:
+		return jsField.Num, true
+	case gjson.String:
+		num, err := strconv.ParseFloat(strings.TrimSpace(jsField.Str), 64)
+		return num, err == nil
+	}
+	return 0, false
+}
+
+// sampler keeps either 1 of every n lines or lines by probability.
+// It's shared by all inputs, so sampling is global across them.
+type sampler struct {
+	every uint64  // Keep 1 of every n lines if set
+	prob  float64 // Probability to keep a line, used if every is not set
+	count uint64  // Number of lines seen, updated atomically
+
+	mu  sync.Mutex // Guards rnd, which is not safe for concurrent use
+	rnd *rand.Rand // Own source so the global one of the importing program is untouched
+}
+
+// newSampler parses sample rate in form of 1/n (e.g. 1/100) or probability (e.g. 0.01).
+func newSampler(rate string) (*sampler, error) {
+	rate = strings.TrimSpace(rate)
+	if idx := strings.Index(rate, "/"); idx >= 0 {
+		num, err1 := strconv.ParseUint(strings.TrimSpace(rate[:idx]), 10, 64)
+		n, err2 := strconv.ParseUint(strings.TrimSpace(rate[idx+1:]), 10, 64)
+		if err1 != nil || err2 != nil || num != 1 || n == 0 {
+			return nil, fmt.Errorf("invalid sample rate %q, expecting 1/n (e.g. 1/100)", rate)
+		}
+		return &sampler{every: n}, nil
+	}
+	prob, err := strconv.ParseFloat(rate, 64)
+	if err != nil || prob <= 0 || prob > 1 {
+		return nil, fmt.Errorf("invalid sample rate %q, expecting probability in (0, 1] (e.g. 0.01)", rate)
+	}
+	return &sampler{prob: prob, rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}, nil
+}
+
+func (s *sampler) keep() bool {
+	if s.every > 0 {
+		// Keep the first line of every n lines
+		return (atomic.AddUint64(&s.count, 1)-1)%s.every == 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rnd.Float64() < s.prob
+}
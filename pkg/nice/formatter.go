@@ -0,0 +1,806 @@
+// Package nice formats JSON (or logfmt) log lines into human-readable output,
+// with fields selection, colors and filters.
+package nice
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/pretty"
+)
+
+// Output formats
+const (
+	OutputText   = "text"
+	OutputJSON   = "json"
+	OutputCSV    = "csv"
+	OutputTable  = "table"
+	OutputPretty = "pretty"
+)
+
+// Input log formats
+const (
+	InputJSON   = "json"
+	InputLogfmt = "logfmt"
+	InputAuto   = "auto"
+)
+
+// Options configures a Formatter. Zero value options are replaced by their defaults.
+// Colors are disabled when color.NoColor of github.com/fatih/color is set.
+type Options struct {
+	Input       string // json (default), logfmt or auto (json, fallback to logfmt if line is not valid JSON)
+	JSONAfter   bool   // Parse JSON starting from the first { of line, ignoring the text prefix
+	PrefixField string // Capture the text prefix skipped by JSONAfter as this field
+	Explode     bool   // Format elements of array or multi-object lines as separate lines
+	Passthrough bool   // Output lines which cannot be parsed as is
+	Raw         bool   // Output lines kept by the filters as is instead of their output fields
+
+	Fields       string // Output fields in form of path[:alias][#color], separated by comma (,)
+	Attrs        string // Output attributes in form of key[:alias][#color], separated by comma (,), after Fields
+	AttrField    string // Field of attributes, as key/value pairs array or object, default to attributes
+	PathSyntax   string // Syntax of field paths: gjson (default, dot notation) or pointer (RFC 6901 JSON Pointer, e.g. /context/user/id)
+	Exclude      string // Output all top-level fields except these, separated by comma (,). Only used when Fields is empty
+	AutoFields   bool   // Output the top-level fields of the first JSON object line, in rotating colors if Colors is empty. Only used when Fields and Exclude are empty
+	Flatten      bool   // Expand object fields to one field per leaf value
+	Output       string // text (default), json, csv, table or pretty (block of field: value lines per line)
+	Template     string // Go text/template of output lines, overrides Output
+	Separator    string // Separator between text output fields, default to tab
+	ArraySep     string // Join array values by this separator instead of printing raw JSON array
+	JSONNested   bool   // Expand dot notation fields into nested objects in JSON output
+	PrettyJSON   bool   // Indent and highlight object or array values in text output
+	MaxLine      int    // Input lines longer than this are skipped by Process and values longer than this are not pretty printed. 0 means DefaultMaxLine for Process and unlimited pretty printing
+	TruncateJSON int    // Truncate object and array values longer than this in non-JSON output, closing their brackets. 0 means unlimited
+	MaxWidth     string // Truncate text output values, in form of N or alias=N, separated by comma (,)
+	Widths       string // Pad text output fields by position, in form of N or >N, separated by comma (,)
+	WrapWidth    int    // Lines of text output wider than this are printed as pretty blocks instead, 0 means never
+	Numeric      string // Aliases of fields right-aligned as numbers even if they're not JSON numbers, separated by comma (,)
+	Missing      string // Placeholder of missing fields, printed in place of them if not empty
+	ShowMissing  bool   // Output Missing placeholder in place of missing fields even if it's empty, e.g. to keep empty columns
+	NullAs       string // Placeholder of null fields in non-JSON output. Null fields are treated as missing if empty
+	BoolFormat   string // Symbols of boolean fields in non-JSON output in form of true=S,false=S, e.g. true=✓,false=✗
+	TableRows    int    // Rows per table of table output, default to 100
+	TableBorder  bool   // Draw box borders in table output
+	TableWidth   int    // Shrink the widest table columns so lines fit in this width, truncating their values. 0 means unlimited
+	Highlight    string // Highlight substrings of text, table and pretty output values matched by this regex
+	TimeField    string // Field of log time, default to time
+	TimeFormat   string // Reformat TimeField by Go time layout
+	Durations    string // Aliases of numeric fields formatted as human-readable durations (e.g. 123.4ms), separated by comma (,)
+	DurationUnit string // Unit of Durations values: ns (default), us, ms or s
+	Sizes        string // Aliases of numeric fields formatted as human-readable byte sizes (e.g. 1.2MB), separated by comma (,)
+	SizeBase     int    // Base of Sizes units: 10 (default, e.g. MB) or 2 (e.g. MiB)
+	Transforms   string // Transforms of non-JSON output values in form of alias=name (upper, lower, trim, basename or json-compact), separated by comma (,)
+
+	Colors     string // Field colors by position, separated by comma (,). A single color applies to all fields
+	ColorMap   string // Line colors by field value, in form of field:value=color, separated by comma (,)
+	KeyColor   string // Color of keys in JSON output and labels in pretty output
+	ValueColor string // Color of values in JSON output, and values without field or line color in pretty output
+	AutoColor  bool   // Color lines by the value of LevelField
+	LevelField string // Field of log level, default to level
+
+	MinLevel    string   // Drop lines having level lower than this level
+	Levels      string   // Log levels ordered by severity, default to DefaultLevels
+	Matches     []string // Regex clauses in form of field=~regex or field!~regex
+	NotMatches  []string // Regex clauses in form of field=~regex, dropping lines matched
+	Wheres      []string // Numeric clauses in form of field<op>number
+	OnBadNumber string   // keep or drop (default) lines with missing or non-numeric Wheres field
+	Since       string   // Drop lines before this time, RFC3339 time or duration before now
+	Until       string   // Drop lines at or after this time, RFC3339 time or duration before now
+	OnBadTime   string   // keep (default) or drop lines with missing or unparseable TimeField when Since or Until is set
+	Invert      bool     // Keep only lines dropped by the filters
+	Sample      string   // Keep a sample of lines passed the filters: 1/n or probability
+}
+
+// Formatter formats log lines into output lines. It's safe for concurrent use.
+type Formatter struct {
+	input       string
+	jsonAfter   bool   // Skip text before the JSON object of line
+	prefixField string // Field to capture the skipped text, dropped if empty
+
+	fields  []outField
+	exclude map[string]bool // Top-level keys to exclude when printing all fields
+	auto    *autoFields     // Fields taken from the first line if set
+
+	hasWildcard bool // Any output field needs to be expanded per line
+	flatten     bool // Expand object fields to their leaf values per line
+	passthrough bool // Print invalid lines as is
+	explode     bool // Print elements of array or multi-object lines separately
+	raw         bool // Print kept lines as is
+
+	colors     []*color.Color
+	sep        string
+	arraySep   string          // Separator to join array elements, raw JSON array is printed if empty
+	prettyJSON bool            // Indent and highlight object or array values in text output
+	maxPretty  int             // Max length of pretty printed values, 0 means unlimited
+	maxLine    int             // Max length of input lines read by Process
+	truncJSON  int             // Max length of object and array values, 0 means unlimited
+	maxWidths  *maxWidths      // Truncate text output values if set
+	widths     []columnWidth   // Pad text output values by position
+	wrapWidth  int             // Max width of text output lines before printed as pretty blocks, 0 means unlimited
+	numeric    map[string]bool // Aliases of fields always aligned as numbers
+	output     string
+	nested     bool         // Nest dot notation keys in JSON output
+	template   *outTemplate // Overrides output format if set
+	table      *tableBuffer // Buffered rows of table output
+	highlight  *regexp.Regexp
+
+	hasMissing bool
+	missing    string                // Placeholder for missing fields
+	nullAs     string                // Placeholder for null fields, empty if they are treated as missing
+	bools      map[gjson.Type]string // Symbols of true and false values if set
+
+	timeField  string
+	timeFormat string // Layout to reformat time field, empty to keep as is
+
+	durations    map[string]bool // Aliases of fields formatted as durations
+	durationUnit time.Duration
+	sizes        map[string]bool // Aliases of fields formatted as byte sizes
+	sizeBase     int
+	transforms   map[string][]func(string) string // Transforms of field values by alias, applied in order
+
+	colorMap   []*valueColor // Colors by field value, has priority over positional colors
+	keyColor   *color.Color  // Color of JSON keys and pretty labels if set
+	valueColor *color.Color  // Color of JSON values and uncolored pretty values if set
+
+	level     *levelFilter
+	matches   []*matchFilter
+	wheres    []*whereFilter
+	timeRange *timeFilter
+	invert    bool // Keep lines failed the filters instead
+	sample    *sampler
+}
+
+// NewFormatter returns a Formatter configured by opts.
+func NewFormatter(opts Options) (*Formatter, error) {
+	opts = withDefaults(opts)
+	switch opts.Input {
+	case InputJSON, InputLogfmt, InputAuto:
+	default:
+		return nil, fmt.Errorf("invalid input %q, expecting json, logfmt or auto", opts.Input)
+	}
+	switch opts.Output {
+	case OutputText, OutputJSON, OutputCSV, OutputTable, OutputPretty:
+	default:
+		return nil, fmt.Errorf("invalid output %q, expecting text, json, csv, table or pretty", opts.Output)
+	}
+	pointer := false
+	switch opts.PathSyntax {
+	case PathGJSON:
+	case PathPointer:
+		pointer = true
+		opts.TimeField, _ = pointerPath(opts.TimeField)
+		opts.LevelField, _ = pointerPath(opts.LevelField)
+		opts.AttrField, _ = pointerPath(opts.AttrField)
+	default:
+		return nil, fmt.Errorf("invalid path syntax %q, expecting gjson or pointer", opts.PathSyntax)
+	}
+	durationUnit, ok := durationUnits[opts.DurationUnit]
+	if !ok {
+		return nil, fmt.Errorf("invalid duration unit %q, expecting ns, us, ms or s", opts.DurationUnit)
+	}
+	if opts.SizeBase != 10 && opts.SizeBase != 2 {
+		return nil, fmt.Errorf("invalid size base %d, expecting 10 or 2", opts.SizeBase)
+	}
+
+	f := &Formatter{
+		input:        opts.Input,
+		jsonAfter:    opts.JSONAfter,
+		prefixField:  opts.PrefixField,
+		fields:       parseFields(opts.Fields),
+		flatten:      opts.Flatten,
+		passthrough:  opts.Passthrough,
+		explode:      opts.Explode,
+		raw:          opts.Raw,
+		colors:       getColorFormat(opts.Colors),
+		sep:          opts.Separator,
+		arraySep:     opts.ArraySep,
+		prettyJSON:   opts.PrettyJSON,
+		maxPretty:    opts.MaxLine,
+		maxLine:      opts.MaxLine,
+		truncJSON:    opts.TruncateJSON,
+		wrapWidth:    opts.WrapWidth,
+		output:       opts.Output,
+		nested:       opts.JSONNested,
+		hasMissing:   opts.ShowMissing || opts.Missing != "",
+		nullAs:       opts.NullAs,
+		missing:      opts.Missing,
+		timeField:    opts.TimeField,
+		timeFormat:   opts.TimeFormat,
+		durationUnit: durationUnit,
+		sizeBase:     opts.SizeBase,
+		invert:       opts.Invert,
+	}
+	if pointer {
+		f.fields = pointerFields(f.fields)
+	}
+	if opts.Attrs != "" {
+		f.fields = append(f.fields, parseAttrFields(opts.Attrs, opts.AttrField, len(f.fields))...)
+	}
+	for _, field := range f.fields {
+		f.hasWildcard = f.hasWildcard || field.wildcard
+	}
+	for _, alias := range strings.Split(opts.Numeric, ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			if f.numeric == nil {
+				f.numeric = make(map[string]bool)
+			}
+			f.numeric[alias] = true
+		}
+	}
+	for _, alias := range strings.Split(opts.Durations, ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			if f.durations == nil {
+				f.durations = make(map[string]bool)
+			}
+			f.durations[alias] = true
+		}
+	}
+	for _, alias := range strings.Split(opts.Sizes, ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			if f.sizes == nil {
+				f.sizes = make(map[string]bool)
+			}
+			f.sizes[alias] = true
+		}
+	}
+	if len(f.fields) == 0 && opts.Exclude == "" && opts.AutoFields {
+		f.auto = &autoFields{colorize: len(f.colors) == 0}
+	}
+	if len(f.fields) == 0 && opts.Exclude != "" {
+		f.exclude = make(map[string]bool)
+		for _, key := range strings.Split(opts.Exclude, ",") {
+			f.exclude[strings.TrimSpace(key)] = true
+		}
+	}
+
+	var err error
+	if opts.MaxWidth != "" {
+		if f.maxWidths, err = parseMaxWidths(opts.MaxWidth); err != nil {
+			return nil, fmt.Errorf("max width: %v", err)
+		}
+	}
+	if opts.Transforms != "" {
+		if f.transforms, err = parseTransforms(opts.Transforms); err != nil {
+			return nil, fmt.Errorf("transforms: %v", err)
+		}
+	}
+	if opts.BoolFormat != "" {
+		if f.bools, err = parseBoolFormat(opts.BoolFormat); err != nil {
+			return nil, fmt.Errorf("bool format: %v", err)
+		}
+	}
+	if f.maxLine <= 0 {
+		f.maxLine = DefaultMaxLine
+	}
+	if opts.TruncateJSON < 0 {
+		return nil, fmt.Errorf("truncate json: invalid length %d", opts.TruncateJSON)
+	}
+	if opts.Widths != "" {
+		if f.widths, err = parseWidths(opts.Widths); err != nil {
+			return nil, fmt.Errorf("widths: %v", err)
+		}
+	}
+	if opts.Output == OutputTable && opts.Template == "" {
+		if opts.TableRows < 0 {
+			return nil, fmt.Errorf("table rows: invalid number of rows %d", opts.TableRows)
+		}
+		f.table = &tableBuffer{size: opts.TableRows, borders: opts.TableBorder, maxWidth: opts.TableWidth}
+	}
+	if opts.Highlight != "" {
+		if f.highlight, err = regexp.Compile(opts.Highlight); err != nil {
+			return nil, fmt.Errorf("highlight: %v", err)
+		}
+	}
+	if opts.Template != "" {
+		if f.template, err = newOutTemplate(opts.Template); err != nil {
+			return nil, fmt.Errorf("template: %v", err)
+		}
+	}
+	if f.colorMap, err = getColorMap(opts.ColorMap); err != nil {
+		return nil, fmt.Errorf("color map: %v", err)
+	}
+	if pointer {
+		for _, vc := range f.colorMap {
+			vc.field, _ = pointerPath(vc.field)
+		}
+	}
+	if colors := getColorFormat(opts.KeyColor); len(colors) > 0 {
+		f.keyColor = colors[0]
+	}
+	if colors := getColorFormat(opts.ValueColor); len(colors) > 0 {
+		f.valueColor = colors[0]
+	}
+	if opts.AutoColor {
+		// Explicit color map takes priority
+		f.colorMap = append(f.colorMap, getLevelColorMap(opts.LevelField)...)
+	}
+
+	if opts.MinLevel != "" {
+		if f.level, err = newLevelFilter(opts.LevelField, opts.MinLevel, opts.Levels); err != nil {
+			return nil, fmt.Errorf("min level: %v", err)
+		}
+	}
+	for _, clause := range opts.Matches {
+		mf, err := newMatchFilter(clause)
+		if err != nil {
+			return nil, fmt.Errorf("match: %v", err)
+		}
+		if pointer {
+			mf.field, _ = pointerPath(mf.field)
+		}
+		f.matches = append(f.matches, mf)
+	}
+	for _, clause := range opts.NotMatches {
+		mf, err := newMatchFilter(clause)
+		if err != nil {
+			return nil, fmt.Errorf("not match: %v", err)
+		}
+		if pointer {
+			mf.field, _ = pointerPath(mf.field)
+		}
+		mf.negate = !mf.negate // field!~regex is field=~regex
+		f.matches = append(f.matches, mf)
+	}
+	for _, clause := range opts.Wheres {
+		wf, err := newWhereFilter(clause, opts.OnBadNumber)
+		if err != nil {
+			return nil, fmt.Errorf("where: %v", err)
+		}
+		if pointer {
+			wf.field, _ = pointerPath(wf.field)
+		}
+		f.wheres = append(f.wheres, wf)
+	}
+	if opts.Since != "" || opts.Until != "" {
+		if f.timeRange, err = newTimeFilter(opts.TimeField, opts.Since, opts.Until, opts.OnBadTime, time.Now()); err != nil {
+			return nil, fmt.Errorf("since/until: %v", err)
+		}
+	}
+	if opts.Sample != "" {
+		if f.sample, err = newSampler(opts.Sample); err != nil {
+			return nil, fmt.Errorf("sample: %v", err)
+		}
+	}
+	return f, nil
+}
+
+func withDefaults(opts Options) Options {
+	if opts.Input == "" {
+		opts.Input = InputJSON
+	}
+	if opts.Output == "" {
+		opts.Output = OutputText
+	}
+	if opts.TableRows == 0 {
+		opts.TableRows = defaultTableRows
+	}
+	if opts.AttrField == "" {
+		opts.AttrField = defaultAttrField
+	}
+	if opts.Separator == "" {
+		opts.Separator = "\t"
+	}
+	if opts.PathSyntax == "" {
+		opts.PathSyntax = PathGJSON
+	}
+	if opts.TimeField == "" {
+		opts.TimeField = "time"
+	}
+	if opts.LevelField == "" {
+		opts.LevelField = "level"
+	}
+	if opts.Levels == "" {
+		opts.Levels = DefaultLevels
+	}
+	if opts.DurationUnit == "" {
+		opts.DurationUnit = "ns"
+	}
+	if opts.SizeBase == 0 {
+		opts.SizeBase = 10
+	}
+	if opts.OnBadNumber == "" {
+		opts.OnBadNumber = "drop"
+	}
+	if opts.OnBadTime == "" {
+		opts.OnBadTime = "keep"
+	}
+	return opts
+}
+
+// Result is the outcome of formatting a line.
+type Result int
+
+const (
+	Printed     Result = iota
+	Skipped            // Line has none of the output fields
+	Filtered           // Line dropped by filters
+	Invalid            // Line cannot be parsed
+	Passthrough        // Line cannot be parsed and printed as is
+)
+
+// EmitFunc is called with each formatted output line, ended by newline, and its parsed
+// log line (zero value for passthrough lines). The output line is only valid until it returns.
+// It reports false if the output line is dropped.
+type EmitFunc func(out []byte, jsonLine gjson.Result) bool
+
+// Format formats line and reports whether it has output. Output lines of exploded
+// lines are separated by newline.
+func (f *Formatter) Format(line []byte) ([]byte, bool) {
+	var out []byte
+	f.FormatFunc(line, &bytes.Buffer{}, func(b []byte, _ gjson.Result) bool {
+		out = append(out, b...)
+		return true
+	})
+	if len(out) == 0 {
+		return nil, false
+	}
+	return out[:len(out)-1], true
+}
+
+// FormatFunc formats line using buff, calls emit with the output lines
+// then returns what happened to the line.
+func (f *Formatter) FormatFunc(line []byte, buff *bytes.Buffer, emit EmitFunc) Result {
+	return f.format(line, buff, emit, true, nil)
+}
+
+// FormatContext formats line like FormatFunc, but without applying the filters,
+// e.g. to print the context lines around matched lines.
+func (f *Formatter) FormatContext(line []byte, buff *bytes.Buffer, emit EmitFunc) Result {
+	return f.format(line, buff, emit, false, nil)
+}
+
+// FormatDiff formats line like FormatFunc, but in text and pretty output, values are dimmed
+// if they're the same as in the previous line formatted by diff, or emboldened if they changed.
+// Values of the first line are colored as usual. Lines dropped by the filters are not compared.
+func (f *Formatter) FormatDiff(line []byte, buff *bytes.Buffer, diff *DiffState, emit EmitFunc) Result {
+	return f.format(line, buff, emit, true, diff)
+}
+
+func (f *Formatter) format(line []byte, buff *bytes.Buffer, emit EmitFunc, filter bool, diff *DiffState) Result {
+	if f.explode {
+		if elems, ok := explodeLine(line); ok {
+			return f.formatExploded(elems, buff, emit, filter, diff)
+		}
+	}
+	jsonLine, valid := f.Parse(line)
+	buff.Reset()
+	return f.formatParsed(line, jsonLine, valid, buff, emit, filter, diff)
+}
+
+// formatExploded formats each element of an exploded line as a separate line.
+// The line is counted as printed if any element printed.
+func (f *Formatter) formatExploded(elems []gjson.Result, buff *bytes.Buffer, emit EmitFunc, filter bool, diff *DiffState) Result {
+	res := Skipped
+	for _, elem := range elems {
+		buff.Reset()
+		switch f.formatParsed([]byte(elem.Raw), elem, true, buff, emit, filter, diff) {
+		case Printed:
+			res = Printed
+		case Filtered:
+			if res == Skipped {
+				res = Filtered
+			}
+		}
+	}
+	return res
+}
+
+// formatParsed formats the parsed jsonLine of line and emits it if it passes the filters, or filter is false.
+// Output values are compared to the previous line if diff is set.
+func (f *Formatter) formatParsed(line []byte, jsonLine gjson.Result, valid bool, buff *bytes.Buffer, emit EmitFunc, filter bool, diff *DiffState) Result {
+	if !valid && f.passthrough {
+		buff.Write(line)
+		buff.WriteString("\n")
+		if !emit(buff.Bytes(), gjson.Result{}) {
+			return Filtered
+		}
+		return Passthrough
+	}
+	if filter && !f.Keep(jsonLine) {
+		return Filtered
+	}
+	if f.raw {
+		buff.Write(line)
+		buff.WriteString("\n")
+		if !emit(buff.Bytes(), jsonLine) {
+			return Filtered
+		}
+		return Printed
+	}
+	if f.table != nil {
+		return f.formatTable(jsonLine, valid, buff, emit)
+	}
+	switch {
+	case f.template != nil:
+		f.formatTemplate(jsonLine, buff)
+	case f.output == OutputJSON:
+		f.formatJSON(jsonLine, buff)
+	case f.output == OutputCSV:
+		f.formatCSV(jsonLine, buff)
+	case f.output == OutputPretty:
+		f.formatBlock(jsonLine, buff, diff)
+		diff.commit()
+	default:
+		f.formatText(jsonLine, buff, diff)
+		if f.wrapWidth > 0 && lineWidth(buff.String()) > f.wrapWidth {
+			buff.Reset()
+			f.formatBlock(jsonLine, buff, diff)
+		}
+		diff.commit()
+	}
+
+	if buff.Len() == 0 {
+		if !valid {
+			return Invalid
+		}
+		return Skipped
+	}
+	buff.WriteString("\n")
+	if !emit(buff.Bytes(), jsonLine) {
+		return Filtered
+	}
+	return Printed
+}
+
+// Parse parses line by the input format and reports whether line is valid in that format.
+func (f *Formatter) Parse(line []byte) (gjson.Result, bool) {
+	if f.jsonAfter && f.input != InputLogfmt {
+		if jsonLine, ok := f.parsePrefixed(line); ok {
+			return jsonLine, true
+		}
+	}
+	switch f.input {
+	case InputLogfmt:
+		return parseLogfmtLine(line)
+	case InputAuto:
+		// JSON objects or arrays, else logfmt if line has key=value pairs, else plain text
+		if gjson.ValidBytes(line) {
+			if jsonLine := gjson.ParseBytes(line); jsonLine.IsObject() || jsonLine.IsArray() {
+				return jsonLine, true
+			}
+		}
+		if jsonLine, ok := detectLogfmt(line); ok {
+			return gjson.ParseBytes(jsonLine), true
+		}
+		return gjson.Result{}, false
+	}
+	return gjson.ParseBytes(line), gjson.ValidBytes(line)
+}
+
+func parseLogfmtLine(line []byte) (gjson.Result, bool) {
+	jsonLine, ok := logfmtToJSON(line)
+	return gjson.ParseBytes(jsonLine), ok
+}
+
+// Header returns the output field aliases joined by separator, ended by newline.
+// It returns nil if there's no header, e.g. JSON and pretty output are already keyed
+// and tables have their own header, or if the output fields depend on lines, see LineHeader.
+func (f *Formatter) Header() []byte {
+	if f.DynamicFields() {
+		return nil
+	}
+	return f.header(f.fields)
+}
+
+// LineHeader returns the header of the output fields of jsonLine, e.g. of the first line
+// when the output fields depend on lines. It returns nil if there's no header.
+func (f *Formatter) LineHeader(jsonLine gjson.Result) []byte {
+	return f.header(f.lineFields(jsonLine))
+}
+
+// DynamicFields reports whether the output fields depend on lines,
+// by Exclude, AutoFields, Flatten or wildcard fields.
+func (f *Formatter) DynamicFields() bool {
+	return f.exclude != nil || f.auto != nil || f.flatten || f.hasWildcard
+}
+
+func (f *Formatter) header(fields []outField) []byte {
+	if f.raw || f.output == OutputJSON || f.output == OutputPretty || f.table != nil || f.template != nil || len(fields) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(fields))
+	for _, field := range fields {
+		names = append(names, field.alias)
+	}
+
+	buff := &bytes.Buffer{}
+	if f.output == OutputCSV {
+		writeCSV(buff, names)
+	} else {
+		for idx, field := range fields {
+			names[idx] = f.pad(field, names[idx], f.numeric[field.alias])
+		}
+		buff.WriteString(strings.Join(names, f.sep))
+	}
+	buff.WriteString("\n")
+	return buff.Bytes()
+}
+
+// formatText writes the output fields of jsonLine to buff, joined by separator.
+// Missing fields are skipped, or replaced by the missing placeholder if configured.
+// Nothing is written if none of the fields has value.
+func (f *Formatter) formatText(jsonLine gjson.Result, buff *bytes.Buffer, diff *DiffState) {
+	lineColor := f.lineColor(jsonLine)
+	hasValue := false
+	columns := 0
+	for _, field := range f.lineFields(jsonLine) {
+		jsField := field.get(jsonLine)
+		val, pretty := f.prettyValue(jsField)
+		if !pretty {
+			val = f.value(field, jsField)
+			if f.maxWidths != nil {
+				// Truncate before coloring so escape codes are not counted
+				val = f.maxWidths.truncate(field, val)
+			}
+			val = highlight(f.highlight, val)
+		}
+		if strings.TrimSpace(val) == "" {
+			if !f.hasMissing {
+				continue
+			}
+			diff.compare(field.alias, "")
+			if columns > 0 {
+				buff.WriteString(f.sep)
+			}
+			buff.WriteString(f.pad(field, f.missing, f.numeric[field.alias]))
+			columns++
+			continue
+		}
+
+		if columns > 0 {
+			buff.WriteString(f.sep)
+		}
+		numeric := f.isNumeric(field, jsField)
+		dc := diff.compare(field.alias, val)
+		if pretty { // Already colored, multiple lines are not padded
+			buff.WriteString(val)
+		} else if lineColor != nil {
+			buff.WriteString(f.pad(field, diffColor(dc, val, lineColor.Sprint(val)), numeric))
+		} else if c := f.fieldColor(field); c != nil {
+			buff.WriteString(f.pad(field, diffColor(dc, val, c.Sprint(val)), numeric))
+		} else {
+			buff.WriteString(f.pad(field, diffColor(dc, val, val), numeric))
+		}
+		columns++
+		hasValue = true
+	}
+
+	if !hasValue {
+		buff.Reset()
+	}
+}
+
+// fieldColor returns the inline or positional color of field, or nil if field has no color.
+// A single color applies to all fields, otherwise fields without color by position
+// are not colored and extra colors are ignored.
+func (f *Formatter) fieldColor(field outField) *color.Color {
+	switch {
+	case field.color != nil:
+		return field.color
+	case len(f.colors) == 1:
+		return f.colors[0]
+	case field.index < len(f.colors):
+		return f.colors[field.index]
+	default:
+		return nil
+	}
+}
+
+// pad pads val to the column width of field by position, if configured.
+// Numbers are right-aligned.
+func (f *Formatter) pad(field outField, val string, numeric bool) string {
+	if field.index >= len(f.widths) {
+		return val
+	}
+	cw := f.widths[field.index]
+	cw.right = cw.right || numeric
+	return cw.pad(val)
+}
+
+// isNumeric reports whether the value of field is aligned as a number: it's a JSON number,
+// not reformatted as time, or field is configured as numeric.
+func (f *Formatter) isNumeric(field outField, jsField gjson.Result) bool {
+	if f.numeric[field.alias] {
+		return true
+	}
+	if f.timeFormat != "" && field.attr == "" && field.path == f.timeField {
+		return false
+	}
+	return jsField.Type == gjson.Number
+}
+
+// prettyValue returns the indented and syntax highlighted JSON of object or array jsField
+// if pretty JSON is enabled. Values longer than the max pretty length are printed as is.
+func (f *Formatter) prettyValue(jsField gjson.Result) (string, bool) {
+	if !f.prettyJSON || !(jsField.IsObject() || jsField.IsArray()) {
+		return "", false
+	}
+	if f.maxPretty > 0 && len(jsField.Raw) > f.maxPretty {
+		return "", false
+	}
+	val := pretty.Pretty([]byte(jsField.Raw))
+	if !color.NoColor {
+		val = pretty.Color(val, nil)
+	}
+	return strings.TrimRight(string(val), "\n"), true
+}
+
+// value returns the printable value of an output field, transformed if configured.
+func (f *Formatter) value(field outField, jsField gjson.Result) string {
+	if f.nullAs != "" && jsField.Type == gjson.Null && jsField.Exists() {
+		return f.nullAs // Explicit null, unlike missing fields
+	}
+	if sym, ok := f.bools[jsField.Type]; ok {
+		return sym
+	}
+	val := f.plainValue(field, jsField)
+	for _, fn := range f.transforms[field.alias] {
+		val = fn(val)
+	}
+	return val
+}
+
+// plainValue returns the printable value of an output field.
+func (f *Formatter) plainValue(field outField, jsField gjson.Result) string {
+	if val, ok := f.convert(field, jsField); ok {
+		return val
+	}
+	if f.arraySep != "" && jsField.IsArray() {
+		// e.g. results of queries like users.#.name
+		elems := jsField.Array()
+		vals := make([]string, 0, len(elems))
+		for _, elem := range elems {
+			vals = append(vals, FieldValue(elem))
+		}
+		return strings.Join(vals, f.arraySep)
+	}
+	if f.truncJSON > 0 && (jsField.IsObject() || jsField.IsArray()) {
+		return truncateJSON(rawValue(jsField), f.truncJSON)
+	}
+	return FieldValue(jsField)
+}
+
+// convert applies configured conversions on an output field value
+// and reports whether the value was converted.
+func (f *Formatter) convert(field outField, jsField gjson.Result) (string, bool) {
+	if f.timeFormat != "" && field.attr == "" && field.path == f.timeField {
+		if t, ok := ParseTime(jsField); ok {
+			return t.Format(f.timeFormat), true
+		}
+	}
+	if f.durations[field.alias] {
+		return formatDuration(jsField, f.durationUnit) // Non-numeric values are printed as is
+	}
+	if f.sizes[field.alias] {
+		return formatSize(jsField, f.sizeBase)
+	}
+	return "", false
+}
+
+// FieldValue returns the printable value of a JSON field.
+// Strings are unquoted, other types are printed as raw JSON. Null and
+// non-existing fields return empty string.
+func FieldValue(jsField gjson.Result) string {
+	switch jsField.Type {
+	case gjson.String:
+		return jsField.Str
+	case gjson.Null:
+		return ""
+	default:
+		return rawValue(jsField)
+	}
+}
+
+// rawValue returns raw JSON of jsField. Values computed by gjson queries
+// (e.g. users.#) have no raw JSON so numbers are formatted from their value.
+func rawValue(jsField gjson.Result) string {
+	if jsField.Raw == "" && jsField.Type == gjson.Number {
+		return strconv.FormatFloat(jsField.Num, 'f', -1, 64)
+	}
+	return jsField.Raw
+}
@@ -0,0 +1,664 @@
+package nice
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/tidwall/gjson"
+)
+
+// formatLine formats JSON line by fields in form of path[:alias], colored by position,
+// and reports whether the line has any of the fields.
+func formatLine(line []byte, fields []string, colors []*color.Color) (string, bool) {
+	f := &Formatter{
+		input:  InputJSON,
+		fields: parseFields(strings.Join(fields, ",")),
+		colors: colors,
+		sep:    "\t",
+	}
+	out, ok := f.Format(line)
+	return string(out), ok
+}
+
+func TestFormatDuplicateFields(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = false
+	defer func() { color.NoColor = noColor }()
+
+	f, err := NewFormatter(Options{
+		Fields: "time,msg,time:ts",
+		Colors: "red,green,blue",
+	})
+	if err != nil {
+		t.Fatalf("NewFormatter() error: %v", err)
+	}
+
+	got, ok := f.Format([]byte(`{"time":"10:00","msg":"hello"}`))
+	if !ok {
+		t.Fatalf("Format() reported no output")
+	}
+	colors := getColorFormat("red,green,blue")
+	want := colors[0].Sprint("10:00") + "\t" + colors[1].Sprint("hello") + "\t" + colors[2].Sprint("10:00")
+	if string(got) != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatLine(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = false
+	defer func() { color.NoColor = noColor }()
+
+	red, green := color.New(color.FgRed), color.New(color.FgGreen)
+	tests := []struct {
+		name   string
+		line   string
+		fields []string
+		colors []*color.Color
+		want   string
+		wantOK bool
+	}{
+		{
+			name:   "all fields",
+			line:   `{"level":"info","msg":"hello"}`,
+			fields: []string{"level", "msg"},
+			want:   "info\thello",
+			wantOK: true,
+		},
+		{
+			name:   "missing field skipped",
+			line:   `{"level":"info"}`,
+			fields: []string{"level", "msg"},
+			want:   "info",
+			wantOK: true,
+		},
+		{
+			name:   "no field exists",
+			line:   `{"foo":"bar"}`,
+			fields: []string{"level", "msg"},
+		},
+		{
+			name:   "null field",
+			line:   `{"level":null}`,
+			fields: []string{"level"},
+		},
+		{
+			name:   "JSON typed fields printed raw",
+			line:   `{"ctx":{"id":1},"tags":["a","b"],"n":1.5,"ok":true}`,
+			fields: []string{"ctx", "tags", "n", "ok"},
+			want:   `{"id":1}` + "\t" + `["a","b"]` + "\t1.5\ttrue",
+			wantOK: true,
+		},
+		{
+			name:   "nested path",
+			line:   `{"ctx":{"user":{"id":7}}}`,
+			fields: []string{"ctx.user.id:uid"},
+			want:   "7",
+			wantOK: true,
+		},
+		{
+			name:   "empty line",
+			line:   ``,
+			fields: []string{"level"},
+		},
+		{
+			name:   "invalid JSON",
+			line:   `level=info`,
+			fields: []string{"level"},
+		},
+		{
+			name:   "single color applies to all fields",
+			line:   `{"level":"info","msg":"hello"}`,
+			fields: []string{"level", "msg"},
+			colors: []*color.Color{red},
+			want:   red.Sprint("info") + "\t" + red.Sprint("hello"),
+			wantOK: true,
+		},
+		{
+			name:   "fewer colors than fields",
+			line:   `{"level":"info","msg":"hello","caller":"main.go"}`,
+			fields: []string{"level", "msg", "caller"},
+			colors: []*color.Color{red, green},
+			want:   red.Sprint("info") + "\t" + green.Sprint("hello") + "\tmain.go",
+			wantOK: true,
+		},
+		{
+			name:   "more colors than fields",
+			line:   `{"level":"info"}`,
+			fields: []string{"level"},
+			colors: []*color.Color{red, green},
+			want:   red.Sprint("info"),
+			wantOK: true,
+		},
+		{
+			name:   "colors by position of missing fields",
+			line:   `{"msg":"hello"}`,
+			fields: []string{"level", "msg"},
+			colors: []*color.Color{red, green},
+			want:   green.Sprint("hello"),
+			wantOK: true,
+		},
+		{
+			name:   "inline colors",
+			line:   `{"level":"info","msg":"hello","caller":"main.go"}`,
+			fields: []string{"level#red", "msg:message#GREEN", "caller"},
+			want:   red.Sprint("info") + "\t" + green.Sprint("hello") + "\tmain.go",
+			wantOK: true,
+		},
+		{
+			name:   "inline color over positional color",
+			line:   `{"level":"info","msg":"hello"}`,
+			fields: []string{"level", "msg#green"},
+			colors: []*color.Color{red},
+			want:   red.Sprint("info") + "\t" + green.Sprint("hello"),
+			wantOK: true,
+		},
+		{
+			name:   "gjson query is not color",
+			line:   `{"users":[{"name":"a"},{"name":"b"}]}`,
+			fields: []string{"users.#", "users.#.name"},
+			want:   "2\t" + `["a","b"]`,
+			wantOK: true,
+		},
+		{
+			name:   "negative array index",
+			line:   `{"events":[{"id":1},{"id":2},{"id":3}]}`,
+			fields: []string{"events.-1.id", "events.-3.id", "events.-4.id"},
+			want:   "3\t1",
+			wantOK: true,
+		},
+		{
+			name:   "negative index of empty array",
+			line:   `{"events":[]}`,
+			fields: []string{"events.-1"},
+			want:   "",
+			wantOK: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := formatLine([]byte(tt.line), tt.fields, tt.colors)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("formatLine() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestFormatTable(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	f, err := NewFormatter(Options{
+		Fields:      "time,msg",
+		Output:      OutputTable,
+		TableRows:   2,
+		TableBorder: true,
+	})
+	if err != nil {
+		t.Fatalf("NewFormatter() error: %v", err)
+	}
+
+	if out, ok := f.Format([]byte(`{"time":"10:00","msg":"hello"}`)); ok {
+		t.Fatalf("Format() = %q, want row buffered", out)
+	}
+	got, ok := f.Format([]byte(`{"time":"10:01","msg":"a\nb"}`))
+	if !ok {
+		t.Fatalf("Format() reported no output for full table")
+	}
+	want := "┌───────┬───────┐\n" +
+		"│ time  │ msg   │\n" +
+		"├───────┼───────┤\n" +
+		"│ 10:00 │ hello │\n" +
+		"│ 10:01 │ a\\nb  │\n" +
+		"└───────┴───────┘"
+	if string(got) != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+	f, err = NewFormatter(Options{Fields: "time,msg", Output: OutputTable, TableRows: 1, TableWidth: 14})
+	if err != nil {
+		t.Fatalf("NewFormatter() error: %v", err)
+	}
+	var lines []string
+	f.FormatFunc([]byte(`{"time":"10:00","msg":"hello world"}`), &bytes.Buffer{}, func(out []byte, _ gjson.Result) bool {
+		lines = append(lines, string(out))
+		return true
+	})
+	if want := []string{"time   msg\n", "10:00  hello …\n"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("FormatFunc() lines = %q, want %q", lines, want)
+	}
+}
+
+func TestFormatAttrs(t *testing.T) {
+	f, err := NewFormatter(Options{
+		Fields: "msg",
+		Attrs:  "http.method,http.status_code:status,user.id",
+	})
+	if err != nil {
+		t.Fatalf("NewFormatter() error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{
+			name: "OpenTelemetry key/value pairs",
+			line: `{"msg":"done","attributes":[{"key":"http.method","value":{"stringValue":"GET"}},{"key":"http.status_code","value":{"intValue":"200"}}]}`,
+			want: "done\tGET\t200",
+		},
+		{
+			name: "plain key/value pairs",
+			line: `{"msg":"done","attributes":[{"key":"user.id","value":7}]}`,
+			want: "done\t7",
+		},
+		{
+			name: "dotted keys object",
+			line: `{"msg":"done","attributes":{"http.method":"POST","user":{"id":7}}}`,
+			want: "done\tPOST\t7",
+		},
+		{
+			name: "no attributes",
+			line: `{"msg":"done"}`,
+			want: "done",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := f.Format([]byte(tt.line))
+			if string(got) != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatPretty(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	f, err := NewFormatter(Options{
+		Fields: "time,level,msg:message",
+		Output: OutputPretty,
+	})
+	if err != nil {
+		t.Fatalf("NewFormatter() error: %v", err)
+	}
+
+	got, ok := f.Format([]byte(`{"time":"10:00","msg":"line 1\nline 2"}`))
+	if !ok {
+		t.Fatalf("Format() reported no output")
+	}
+	want := "time:    10:00\nmessage: line 1\n  line 2\n"
+	if string(got) != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = false
+	defer func() { color.NoColor = noColor }()
+
+	tests := []struct {
+		name    string
+		pattern string
+		val     string
+		want    string
+	}{
+		{
+			name:    "all matches",
+			pattern: "o",
+			val:     "foo bar boo",
+			want:    "f" + highlightOn + "o" + highlightOff + highlightOn + "o" + highlightOff + " bar b" + highlightOn + "o" + highlightOff + highlightOn + "o" + highlightOff,
+		},
+		{
+			name:    "overlapping matches are leftmost first",
+			pattern: "aa",
+			val:     "aaa",
+			want:    highlightOn + "aa" + highlightOff + "a",
+		},
+		{
+			name:    "empty matches ignored",
+			pattern: "x*",
+			val:     "ab",
+			want:    "ab",
+		},
+		{
+			name:    "multiline match",
+			pattern: "a\nb",
+			val:     "a\nb",
+			want:    highlightOn + "a" + highlightOff + "\n" + highlightOn + "b" + highlightOff,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlight(regexp.MustCompile(tt.pattern), tt.val); got != tt.want {
+				t.Errorf("highlight() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatNumericAlign(t *testing.T) {
+	f, err := NewFormatter(Options{
+		Fields:  "msg,latency,status",
+		Widths:  "6,6,6",
+		Numeric: "status",
+	})
+	if err != nil {
+		t.Fatalf("NewFormatter() error: %v", err)
+	}
+
+	got, _ := f.Format([]byte(`{"msg":"ok","latency":1.5,"status":"200"}`))
+	if want := "ok    \t   1.5\t   200"; string(got) != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+	if got, want := string(f.Header()), "msg   \tlatency\tstatus\n"; got != want {
+		t.Errorf("Header() = %q, want %q", got, want)
+	}
+}
+
+func TestLineHeader(t *testing.T) {
+	jsonLine := gjson.Parse(`{"user":{"id":1,"name":"bob"},"ctx":{"a":{"b":1}}}`)
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{name: "wildcard", opts: Options{Fields: "user.*"}, want: "user.id\tuser.name\n"},
+		{name: "flatten", opts: Options{Fields: "ctx", Flatten: true}, want: "ctx.a.b\n"},
+		{name: "exclude", opts: Options{Exclude: "ctx", Output: OutputCSV}, want: "user\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFormatter(tt.opts)
+			if err != nil {
+				t.Fatalf("NewFormatter() error: %v", err)
+			}
+			if !f.DynamicFields() || f.Header() != nil {
+				t.Errorf("DynamicFields() = %v, Header() = %q, want true, nil", f.DynamicFields(), f.Header())
+			}
+			if got := string(f.LineHeader(jsonLine)); got != tt.want {
+				t.Errorf("LineHeader() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAuto(t *testing.T) {
+	f, err := NewFormatter(Options{Input: InputAuto, Fields: "msg"})
+	if err != nil {
+		t.Fatalf("NewFormatter() error: %v", err)
+	}
+
+	tests := []struct {
+		line      string
+		wantMsg   string
+		wantValid bool
+	}{
+		{line: `{"msg":"json"}`, wantMsg: "json", wantValid: true},
+		{line: `level=info msg="logfmt line"`, wantMsg: "logfmt line", wantValid: true},
+		{line: `plain text line`, wantValid: false},
+		{line: `42`, wantValid: false},
+	}
+	for _, tt := range tests {
+		jsonLine, valid := f.Parse([]byte(tt.line))
+		if valid != tt.wantValid {
+			t.Errorf("Parse(%q) valid = %v, want %v", tt.line, valid, tt.wantValid)
+		}
+		if got := jsonLine.Get("msg").String(); got != tt.wantMsg {
+			t.Errorf("Parse(%q) msg = %q, want %q", tt.line, got, tt.wantMsg)
+		}
+	}
+}
+
+func TestFormatDurations(t *testing.T) {
+	f, err := NewFormatter(Options{
+		Fields:       "a,b,c,d",
+		Durations:    "a,b,c,d",
+		DurationUnit: "us",
+	})
+	if err != nil {
+		t.Fatalf("NewFormatter() error: %v", err)
+	}
+
+	got, _ := f.Format([]byte(`{"a":123456,"b":"1500","c":83456789,"d":"slow"}`))
+	want := "123.4ms\t1.5ms\t1m23.45s\tslow"
+	if string(got) != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatTransforms(t *testing.T) {
+	f, err := NewFormatter(Options{Fields: "level,path,doc", Transforms: "level=trim,level=upper,path=basename,doc=json-compact"})
+	if err != nil {
+		t.Fatalf("NewFormatter() error: %v", err)
+	}
+
+	got, _ := f.Format([]byte(`{"level":" warn ","path":"/var/log/app.log","doc":"{ \"a\": [1, 2] }"}`))
+	if want := "WARN\tapp.log\t{\"a\":[1,2]}"; string(got) != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+
+	if _, err := NewFormatter(Options{Transforms: "level=reverse"}); err == nil {
+		t.Errorf("NewFormatter() with unknown transform error = nil, want error")
+	}
+}
+
+func TestFormatNullAs(t *testing.T) {
+	f, err := NewFormatter(Options{Fields: "user,msg", NullAs: "null", ShowMissing: true, Missing: "-"})
+	if err != nil {
+		t.Fatalf("NewFormatter() error: %v", err)
+	}
+
+	lines := []string{`{"user":null,"msg":"a"}`, `{"msg":"b"}`, `{"user":"","msg":"c"}`}
+	want := []string{"null\ta", "-\tb", "-\tc"}
+	for idx, line := range lines {
+		got, _ := f.Format([]byte(line))
+		if string(got) != want[idx] {
+			t.Errorf("Format(%q) = %q, want %q", line, got, want[idx])
+		}
+	}
+}
+
+func TestFormatBoolFormat(t *testing.T) {
+	f, err := NewFormatter(Options{Fields: "a,b,c", BoolFormat: "true=✓,false=✗"})
+	if err != nil {
+		t.Fatalf("NewFormatter() error: %v", err)
+	}
+
+	got, _ := f.Format([]byte(`{"a":true,"b":false,"c":"true"}`))
+	if want := "✓\t✗\ttrue"; string(got) != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+
+	if _, err := NewFormatter(Options{BoolFormat: "yes=1"}); err == nil {
+		t.Errorf("NewFormatter() with invalid bool format error = nil, want error")
+	}
+}
+
+func TestFormatAutoFields(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	f, err := NewFormatter(Options{AutoFields: true, Missing: "-"})
+	if err != nil {
+		t.Fatalf("NewFormatter() error: %v", err)
+	}
+
+	lines := []string{`not json`, `{}`, `{"time":1,"msg":"first"}`, `{"msg":"second","extra":true}`}
+	want := []string{"", "", "1\tfirst", "-\tsecond"}
+	for idx, line := range lines {
+		got, _ := f.Format([]byte(line))
+		if string(got) != want[idx] {
+			t.Errorf("Format(%q) = %q, want %q", line, got, want[idx])
+		}
+	}
+}
+
+func TestFormatWrapWidth(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	f, err := NewFormatter(Options{Fields: "msg,user", WrapWidth: 12})
+	if err != nil {
+		t.Fatalf("NewFormatter() error: %v", err)
+	}
+
+	lines := []string{`{"msg":"hi","user":"bob"}`, `{"msg":"a long message","user":"bob"}`}
+	want := []string{"hi\tbob", "msg:  a long message\nuser: bob\n"}
+	for idx, line := range lines {
+		got, _ := f.Format([]byte(line))
+		if string(got) != want[idx] {
+			t.Errorf("Format(%q) = %q, want %q", line, got, want[idx])
+		}
+	}
+}
+
+func TestFormatPointerPaths(t *testing.T) {
+	f, err := NewFormatter(Options{
+		Fields:     "/context/user/id,/a~1b,/m~0n,/m~01:tilde,level",
+		PathSyntax: PathPointer,
+		Output:     OutputJSON,
+	})
+	if err != nil {
+		t.Fatalf("NewFormatter() error: %v", err)
+	}
+
+	got, _ := f.Format([]byte(`{"context":{"user":{"id":7}},"a/b":1,"m~n":2,"m~1":3,"level":"info"}`))
+	want := `{"context.user.id":7,"a/b":1,"m~n":2,"tilde":3,"level":"info"}`
+	if string(got) != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatDiff(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = false
+	defer func() { color.NoColor = noColor }()
+
+	f, err := NewFormatter(Options{Fields: "state,n"})
+	if err != nil {
+		t.Fatalf("NewFormatter() error: %v", err)
+	}
+
+	diff := &DiffState{}
+	lines := []string{`{"state":"a","n":1}`, `{"state":"a","n":2}`}
+	want := []string{
+		"a\t1\n",
+		diffUnchanged.Sprint("a") + "\t" + diffChanged.Sprint("2") + "\n",
+	}
+	for idx, line := range lines {
+		var got string
+		f.FormatDiff([]byte(line), &bytes.Buffer{}, diff, func(out []byte, _ gjson.Result) bool {
+			got = string(out)
+			return true
+		})
+		if got != want[idx] {
+			t.Errorf("FormatDiff(%q) = %q, want %q", line, got, want[idx])
+		}
+	}
+}
+
+func TestLookupExtendedColor(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = false
+	defer func() { color.NoColor = noColor }()
+	defer func(tc bool) { trueColor = tc }(trueColor)
+
+	tests := []struct {
+		token     string
+		trueColor bool
+		want      string
+	}{
+		{token: "256:214", want: "\x1b[38;5;214mx\x1b[0m"},
+		{token: "red:256:17", want: "\x1b[31;48;5;17mx\x1b[0m"},
+		{token: "#ff8800+bold", trueColor: true, want: "\x1b[38;2;255;136;0;1mx\x1b[0m"},
+		{token: "#ff8800", want: "\x1b[33mx\x1b[0m"},
+		{token: "256:300"},
+		{token: "#ff88"},
+	}
+	for _, tt := range tests {
+		trueColor = tt.trueColor
+		c, ok := lookupColor(tt.token)
+		if ok != (tt.want != "") {
+			t.Errorf("lookupColor(%q) ok = %v", tt.token, ok)
+			continue
+		}
+		if ok && c.Sprint("x") != tt.want {
+			t.Errorf("lookupColor(%q) = %q, want %q", tt.token, c.Sprint("x"), tt.want)
+		}
+	}
+}
+
+func TestTruncateJSON(t *testing.T) {
+	tests := []struct {
+		raw  string
+		n    int
+		want string
+	}{
+		{raw: `{"a":1}`, n: 10, want: `{"a":1}`},
+		{raw: `{"k":"hello world"}`, n: 10, want: `{"k":"hell…"}`},
+		{raw: `{"a":[1,2,3]}`, n: 8, want: `{"a":[1,…]}`},
+		{raw: `["a\"b"]`, n: 4, want: `["a…"]`},
+		{raw: `["héllo wörld"]`, n: 5, want: `["hél…"]`},
+	}
+	for _, tt := range tests {
+		if got := truncateJSON(tt.raw, tt.n); got != tt.want {
+			t.Errorf("truncateJSON(%q, %d) = %q, want %q", tt.raw, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFormatterProcess(t *testing.T) {
+	f, err := NewFormatter(Options{Fields: "msg", MinLevel: "info"})
+	if err != nil {
+		t.Fatalf("NewFormatter() error: %v", err)
+	}
+
+	in := strings.NewReader("{\"level\":\"info\",\"msg\":\"a\"}\nnot json\n{\"level\":\"debug\",\"msg\":\"b\"}\n{\"msg\":\"c\"}\n")
+	out := &bytes.Buffer{}
+	stats, err := f.Process(context.Background(), in, out)
+	if err != nil {
+		t.Fatalf("Process() error: %v", err)
+	}
+	if got, want := out.String(), "a\nc\n"; got != want {
+		t.Errorf("Process() output = %q, want %q", got, want)
+	}
+	if want := (Stats{Lines: 4, Printed: 2, Filtered: 1, Invalid: 1}); stats != want {
+		t.Errorf("Process() stats = %+v, want %+v", stats, want)
+	}
+
+	f, err = NewFormatter(Options{Fields: "msg", MaxLine: 16})
+	if err != nil {
+		t.Fatalf("NewFormatter() error: %v", err)
+	}
+	out.Reset()
+	in = strings.NewReader("{\"msg\":\"a\"}\n{\"msg\":\"too long line\"}\n{\"msg\":\"b\"}")
+	if stats, err = f.Process(context.Background(), in, out); err != nil {
+		t.Fatalf("Process() error: %v", err)
+	}
+	if got, want := out.String(), "a\nb\n"; got != want {
+		t.Errorf("Process() output = %q, want %q", got, want)
+	}
+	if want := (Stats{Lines: 2, Printed: 2, TooLong: 1}); stats != want {
+		t.Errorf("Process() stats = %+v, want %+v", stats, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f.Process(ctx, strings.NewReader("{\"msg\":\"a\"}\n"), out); err != context.Canceled {
+		t.Errorf("Process() with cancelled context error = %v, want %v", err, context.Canceled)
+	}
+}
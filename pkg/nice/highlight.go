@@ -0,0 +1,42 @@
+package nice
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Reverse video on and off escape codes. Turning reverse off doesn't reset other
+// attributes so highlights can be nested in field and line colors.
+const (
+	highlightOn  = "\x1b[7m"
+	highlightOff = "\x1b[27m"
+)
+
+// highlight wraps the substrings of val matched by re in reverse video, like grep --color.
+// Matches are leftmost non-overlapping as found by regexp, empty matches are ignored.
+// Matches spanning multiple lines are highlighted line by line.
+func highlight(re *regexp.Regexp, val string) string {
+	if re == nil || color.NoColor {
+		return val
+	}
+	matches := re.FindAllStringIndex(val, -1)
+	if len(matches) == 0 {
+		return val
+	}
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		if m[0] == m[1] {
+			continue
+		}
+		sb.WriteString(val[last:m[0]])
+		sb.WriteString(highlightOn)
+		sb.WriteString(strings.Replace(val[m[0]:m[1]], "\n", highlightOff+"\n"+highlightOn, -1))
+		sb.WriteString(highlightOff)
+		last = m[1]
+	}
+	sb.WriteString(val[last:])
+	return sb.String()
+}
@@ -0,0 +1,98 @@
+package nice
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/tidwall/gjson"
+)
+
+// formatJSON writes the output fields of jsonLine to buff as a JSON object, keyed by field aliases.
+// Fields not existing in jsonLine are omitted. Nothing is written if none of
+// the fields exists.
+func (f *Formatter) formatJSON(jsonLine gjson.Result, buff *bytes.Buffer) {
+	root := &jsonNode{}
+	for _, field := range f.lineFields(jsonLine) {
+		jsField := field.get(jsonLine)
+		if !jsField.Exists() {
+			continue
+		}
+		raw := rawValue(jsField)
+		if val, ok := f.convert(field, jsField); ok {
+			b, _ := json.Marshal(val)
+			raw = string(b)
+		}
+		if f.nested {
+			root.insert(strings.Split(field.alias, "."), raw)
+		} else {
+			root.insert([]string{field.alias}, raw)
+		}
+	}
+
+	if len(root.children) == 0 {
+		return
+	}
+	root.writeTo(buff, f.keyColor, f.valueColor)
+}
+
+// jsonNode is an object key in the JSON output, keeping keys in insertion order.
+// A node holds either a raw JSON value or children keys.
+type jsonNode struct {
+	key      string
+	raw      string
+	children []*jsonNode
+}
+
+// insert sets raw as the value of the nested keys.
+// Keys conflicting with an existed value are ignored, first value wins.
+func (n *jsonNode) insert(keys []string, raw string) {
+	if n.raw != "" {
+		return
+	}
+	if len(keys) == 0 {
+		if len(n.children) == 0 {
+			n.raw = raw
+		}
+		return
+	}
+
+	for _, child := range n.children {
+		if child.key == keys[0] {
+			child.insert(keys[1:], raw)
+			return
+		}
+	}
+	child := &jsonNode{key: keys[0]}
+	n.children = append(n.children, child)
+	child.insert(keys[1:], raw)
+}
+
+// writeTo writes the JSON of n to buff, with keys and values colored if colors are set.
+func (n *jsonNode) writeTo(buff *bytes.Buffer, keyColor, valueColor *color.Color) {
+	if n.raw != "" {
+		if valueColor != nil {
+			buff.WriteString(valueColor.Sprint(n.raw))
+		} else {
+			buff.WriteString(n.raw)
+		}
+		return
+	}
+
+	buff.WriteByte('{')
+	for idx, child := range n.children {
+		if idx > 0 {
+			buff.WriteByte(',')
+		}
+		key, _ := json.Marshal(child.key)
+		if keyColor != nil {
+			buff.WriteString(keyColor.Sprint(string(key)))
+		} else {
+			buff.Write(key)
+		}
+		buff.WriteByte(':')
+		child.writeTo(buff, keyColor, valueColor)
+	}
+	buff.WriteByte('}')
+}
@@ -0,0 +1,110 @@
+package nice
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// logfmtToJSON converts a logfmt line (key=value key2="quoted value") to a JSON object,
+// so fields can be selected the same way as JSON logs.
+// Dot notation keys are expanded into nested objects, keys without value are set to true.
+// It reports false if line has no key/value pair.
+func logfmtToJSON(line []byte) ([]byte, bool) {
+	pairs := parseLogfmt(line)
+	return pairsToJSON(pairs, len(line)), len(pairs) > 0
+}
+
+// detectLogfmt converts line to a JSON object like logfmtToJSON, but reports false
+// if line has no key=value pair, e.g. plain text whose words would be parsed as bare keys.
+func detectLogfmt(line []byte) ([]byte, bool) {
+	pairs := parseLogfmt(line)
+	for _, pair := range pairs {
+		if pair.hasValue {
+			return pairsToJSON(pairs, len(line)), true
+		}
+	}
+	return nil, false
+}
+
+func pairsToJSON(pairs []logfmtPair, size int) []byte {
+	root := &jsonNode{}
+	for _, pair := range pairs {
+		raw := "true"
+		if pair.hasValue {
+			b, _ := json.Marshal(pair.value)
+			raw = string(b)
+		}
+		root.insert(strings.Split(pair.key, "."), raw)
+	}
+
+	buff := bytes.NewBuffer(make([]byte, 0, size+32))
+	root.writeTo(buff, nil, nil)
+	return buff.Bytes()
+}
+
+type logfmtPair struct {
+	key      string
+	value    string
+	hasValue bool
+}
+
+// parseLogfmt splits a logfmt line into key/value pairs.
+// Quoted values may contain spaces and backslash escaped characters.
+func parseLogfmt(line []byte) []logfmtPair {
+	var pairs []logfmtPair
+	i := 0
+	for i < len(line) {
+		// Skip spaces between pairs
+		for i < len(line) && line[i] <= ' ' {
+			i++
+		}
+		start := i
+		for i < len(line) && line[i] > ' ' && line[i] != '=' {
+			i++
+		}
+		if i == start {
+			// Garbage without key, skip it
+			if i < len(line) {
+				i++
+			}
+			continue
+		}
+		pair := logfmtPair{key: string(line[start:i])}
+		if i >= len(line) || line[i] != '=' {
+			pairs = append(pairs, pair)
+			continue
+		}
+
+		i++ // '='
+		pair.hasValue = true
+		if i < len(line) && line[i] == '"' {
+			var val strings.Builder
+			for i++; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+					switch line[i] {
+					case 'n':
+						val.WriteByte('\n')
+					case 't':
+						val.WriteByte('\t')
+					default:
+						val.WriteByte(line[i])
+					}
+					continue
+				}
+				val.WriteByte(line[i])
+			}
+			i++ // Closing quote
+			pair.value = val.String()
+		} else {
+			start = i
+			for i < len(line) && line[i] > ' ' {
+				i++
+			}
+			pair.value = string(line[start:i])
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs
+}
@@ -0,0 +1,42 @@
+package nice
+
+import "strings"
+
+// Path syntaxes of field paths
+const (
+	PathGJSON   = "gjson"
+	PathPointer = "pointer"
+)
+
+// pointerPath translates a RFC 6901 JSON Pointer (e.g. /context/user/id) to gjson path
+// and the dot notation name of the pointed field. Paths not starting with / are returned as is.
+func pointerPath(pointer string) (path, name string) {
+	if !strings.HasPrefix(pointer, "/") {
+		return pointer, pointer
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	paths := make([]string, len(tokens))
+	for idx, token := range tokens {
+		// ~1 must be unescaped first, so ~01 becomes ~1 instead of /
+		tokens[idx] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+		paths[idx] = escapePath(tokens[idx])
+	}
+	return strings.Join(paths, "."), strings.Join(tokens, ".")
+}
+
+// pointerFields translates JSON Pointer paths of fields to gjson paths.
+// Fields without alias are named by the dot notation of their pointers.
+func pointerFields(fields []outField) []outField {
+	for idx, field := range fields {
+		if !strings.HasPrefix(field.path, "/") {
+			continue
+		}
+		path, name := pointerPath(field.path)
+		if field.alias == field.path {
+			fields[idx].alias = name
+		}
+		fields[idx].path = path
+		fields[idx].wildcard = false // Pointer tokens are matched as is
+	}
+	return fields
+}
@@ -0,0 +1,78 @@
+package nice
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// parsePrefixed parses the first balanced JSON object of line, ignoring any text before
+// (e.g. timestamp or container name added by docker/k8s) and after it.
+// If prefixField is configured, the trimmed text before the object is added as that field.
+func (f *Formatter) parsePrefixed(line []byte) (gjson.Result, bool) {
+	start, end, ok := findJSONObject(line)
+	if !ok {
+		return gjson.Result{}, false
+	}
+	obj := line[start:end]
+	if !gjson.ValidBytes(obj) {
+		return gjson.Result{}, false
+	}
+
+	prefix := strings.TrimSpace(string(line[:start]))
+	if f.prefixField == "" || prefix == "" {
+		return gjson.ParseBytes(obj), true
+	}
+	buff := bytes.NewBuffer(make([]byte, 0, len(obj)+len(f.prefixField)+len(prefix)+8))
+	key, _ := json.Marshal(f.prefixField)
+	val, _ := json.Marshal(prefix)
+	buff.WriteByte('{')
+	buff.Write(key)
+	buff.WriteByte(':')
+	buff.Write(val)
+	if rest := bytes.TrimSpace(obj[1:]); len(rest) > 0 && rest[0] != '}' {
+		buff.WriteByte(',')
+	}
+	buff.Write(obj[1:])
+	return gjson.ParseBytes(buff.Bytes()), true
+}
+
+// findJSONObject returns the position [start, end) of the first balanced {...} of line.
+// Braces inside JSON strings are skipped.
+func findJSONObject(line []byte) (int, int, bool) {
+	start := bytes.IndexByte(line, '{')
+	if start < 0 {
+		return 0, 0, false
+	}
+
+	depth := 0
+	inString, escaped := false, false
+	for i := start; i < len(line); i++ {
+		c := line[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return start, i + 1, true
+			}
+		}
+	}
+	return 0, 0, false
+}
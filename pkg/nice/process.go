@@ -0,0 +1,90 @@
+package nice
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/tidwall/gjson"
+)
+
+// Stats counts the lines processed by Process by their results.
+type Stats struct {
+	Lines       uint64 // Lines read
+	Printed     uint64
+	Skipped     uint64
+	Filtered    uint64
+	Invalid     uint64
+	Passthrough uint64
+	TooLong     uint64 // Lines longer than MaxLine, skipped
+}
+
+func (s *Stats) add(res Result) {
+	s.Lines++
+	switch res {
+	case Printed:
+		s.Printed++
+	case Skipped:
+		s.Skipped++
+	case Filtered:
+		s.Filtered++
+	case Invalid:
+		s.Invalid++
+	case Passthrough:
+		s.Passthrough++
+	}
+}
+
+// Process formats the lines read from r by opts and writes the output lines to w,
+// until EOF, a read or write error, or ctx cancelled. See Formatter.Process.
+func Process(ctx context.Context, r io.Reader, w io.Writer, opts Options) error {
+	f, err := NewFormatter(opts)
+	if err != nil {
+		return err
+	}
+	_, err = f.Process(ctx, r, w)
+	return err
+}
+
+// Process formats the lines read from r and writes the output lines to w, until EOF,
+// a read or write error, or ctx cancelled, then returns the line counts so far.
+// Each output line is written before the next line is read, so a slow w slows
+// reading down instead of buffering lines. Lines longer than MaxLine are skipped. Cancellation is checked between lines,
+// closing r unblocks a pending read. Rows of table output are flushed on EOF.
+// The header is not written, see Header.
+func (f *Formatter) Process(ctx context.Context, r io.Reader, w io.Writer) (Stats, error) {
+	var stats Stats
+	var writeErr error
+	emit := func(out []byte, _ gjson.Result) bool {
+		if writeErr != nil {
+			return false
+		}
+		if _, writeErr = w.Write(out); writeErr != nil {
+			return false
+		}
+		return true
+	}
+
+	splitter := &LineSplitter{Max: f.maxLine, OnSkip: func() { stats.TooLong++ }}
+	buff := &bytes.Buffer{}
+	err := ScanLines(ctx, splitter.Scanner(r), func(line []byte) error {
+		stats.add(f.FormatFunc(line, buff, emit))
+		if writeErr != nil {
+			return fmt.Errorf("failed to write output: %v", writeErr)
+		}
+		return nil
+	})
+	switch {
+	case err != nil && (err == ctx.Err() || writeErr != nil):
+		return stats, err
+	case err != nil:
+		return stats, fmt.Errorf("failed to read input: %v", err)
+	}
+
+	f.Flush(emit)
+	if writeErr != nil {
+		return stats, fmt.Errorf("failed to write output: %v", writeErr)
+	}
+	return stats, nil
+}
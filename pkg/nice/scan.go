@@ -0,0 +1,93 @@
+package nice
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+)
+
+// DefaultMaxLine is the maximum length of input lines read by Process if MaxLine is not set, in bytes.
+const DefaultMaxLine = 1024 * 1024
+
+// LineSplitter splits lines like bufio.ScanLines, but skips lines longer than Max bytes
+// instead of failing the scan with bufio.ErrTooLong.
+type LineSplitter struct {
+	Max    int         // Maximum length of lines in bytes
+	OnRead func(n int) // Called with the number of consumed bytes if set, e.g. to track the read offset
+	OnSkip func()      // Called on each skipped line if set
+
+	skipping bool // Discarding the rest of a too long line
+}
+
+// Split is a bufio.SplitFunc splitting lines of up to Max bytes.
+func (s *LineSplitter) Split(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := s.next(data, atEOF)
+	if advance > 0 && s.OnRead != nil {
+		s.OnRead(advance)
+	}
+	return advance, token, err
+}
+
+func (s *LineSplitter) next(data []byte, atEOF bool) (int, []byte, error) {
+	if s.skipping {
+		idx := bytes.IndexByte(data, '\n')
+		if idx < 0 {
+			return len(data), nil, nil
+		}
+		s.skipping = false
+		return idx + 1, nil, nil
+	}
+
+	advance, token, err := bufio.ScanLines(data, atEOF)
+	if token == nil && len(data) > s.Max {
+		// Buffer is full without newline, drop what's read so far and the rest of the line
+		s.skip()
+		s.skipping = true
+		return len(data), nil, nil
+	}
+	if len(token) > s.Max {
+		s.skip()
+		return advance, nil, nil
+	}
+	return advance, token, err
+}
+
+func (s *LineSplitter) skip() {
+	if s.OnSkip != nil {
+		s.OnSkip()
+	}
+}
+
+// Scanner returns a scanner of the lines of r split by s.
+func (s *LineSplitter) Scanner(r io.Reader) *bufio.Scanner {
+	size := 64 * 1024
+	if s.Max < size {
+		size = s.Max
+	}
+	scanner := bufio.NewScanner(r)
+	// One more byte than max so a full buffer without newline means a too long line
+	scanner.Buffer(make([]byte, 0, size+1), s.Max+1)
+	scanner.Split(s.Split)
+	return scanner
+}
+
+// ScanLines calls fn on each line of scanner until EOF, ctx cancelled or fn returns an error,
+// then returns the error which stopped scanning, or nil on EOF.
+// Cancellation is checked between lines. The line is only valid until fn returns.
+func ScanLines(ctx context.Context, scanner *bufio.Scanner, fn func(line []byte) error) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !scanner.Scan() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return scanner.Err()
+		}
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+}
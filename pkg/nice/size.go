@@ -0,0 +1,42 @@
+package nice
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Units of humanized sizes by base.
+var (
+	sizeUnits10 = []string{"B", "KB", "MB", "GB", "TB", "PB", "EB"}
+	sizeUnits2  = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+)
+
+// formatSize formats jsField, a number of bytes, as a human-readable size in base 10
+// (e.g. 1.2MB) or base 2 (e.g. 1.1MiB), and reports whether jsField is numeric.
+func formatSize(jsField gjson.Result, base int) (string, bool) {
+	num, ok := numberValue(jsField)
+	if !ok {
+		return "", false
+	}
+	units, step := sizeUnits10, 1000.0
+	if base == 2 {
+		units, step = sizeUnits2, 1024.0
+	}
+
+	sign := ""
+	if num < 0 {
+		sign, num = "-", -num
+	}
+	idx := 0
+	for num >= step && idx < len(units)-1 {
+		num /= step
+		idx++
+	}
+	val := strconv.FormatFloat(num, 'f', 1, 64)
+	if idx == 0 {
+		val = strconv.FormatFloat(num, 'f', -1, 64) // Bytes are never fractional in practice
+	}
+	return sign + strings.TrimSuffix(val, ".0") + units[idx], true
+}
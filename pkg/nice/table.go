@@ -0,0 +1,244 @@
+package nice
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/tidwall/gjson"
+)
+
+// defaultTableRows is the default number of rows per rendered table.
+const defaultTableRows = 100
+
+// minTableColumn is the minimum width of columns shrunk to fit tables in their max width.
+const minTableColumn = 4
+
+// tableBuffer accumulates table rows until a table is full to compute the column widths.
+type tableBuffer struct {
+	size     int  // Number of rows per table
+	borders  bool // Draw box borders around cells
+	maxWidth int  // Shrink the widest columns so lines fit in this width if set
+
+	mu      sync.Mutex
+	columns []string // Column names in order of first appearance
+	rows    []map[string]tableCell
+}
+
+// tableCell is a cell value of table output.
+type tableCell struct {
+	val     string
+	numeric bool // Right-aligned if all values of the column are numeric
+	missing bool // Missing values don't affect the column alignment
+}
+
+// formatTable adds jsonLine as a row of the buffered table and emits the table line by line
+// once it's full. Lines are counted as printed when they're buffered.
+func (f *Formatter) formatTable(jsonLine gjson.Result, valid bool, buff *bytes.Buffer, emit EmitFunc) Result {
+	row, names, hasValue := f.tableRow(jsonLine)
+	if !hasValue {
+		if !valid {
+			return Invalid
+		}
+		return Skipped
+	}
+	f.table.add(row, names, buff, emit)
+	return Printed
+}
+
+// Flush emits the remaining buffered rows of table output as a table, line by line.
+// It's a no-op for other output formats.
+func (f *Formatter) Flush(emit EmitFunc) {
+	if f.table == nil {
+		return
+	}
+	f.table.flush(&bytes.Buffer{}, emit)
+}
+
+// tableRow returns the cells of jsonLine keyed by field alias, the aliases in order
+// and whether any of the fields has value. Missing fields are empty or the missing placeholder.
+func (f *Formatter) tableRow(jsonLine gjson.Result) (map[string]tableCell, []string, bool) {
+	fields := f.lineFields(jsonLine)
+	cells := make(map[string]tableCell, len(fields))
+	names := make([]string, 0, len(fields))
+	lineColor := f.lineColor(jsonLine)
+	hasValue := false
+	for _, field := range fields {
+		if _, ok := cells[field.alias]; ok {
+			continue // First value wins on duplicated aliases
+		}
+		names = append(names, field.alias)
+		jsField := field.get(jsonLine)
+		val := f.value(field, jsField)
+		// Multiline values would break the table
+		val = strings.Replace(val, "\n", `\n`, -1)
+		if f.maxWidths != nil {
+			val = f.maxWidths.truncate(field, val)
+		}
+		val = highlight(f.highlight, val)
+		if strings.TrimSpace(val) == "" {
+			cells[field.alias] = tableCell{val: f.missing, missing: true}
+			continue
+		}
+		if lineColor != nil {
+			val = lineColor.Sprint(val)
+		} else if c := f.fieldColor(field); c != nil {
+			val = c.Sprint(val)
+		}
+		cells[field.alias] = tableCell{val: val, numeric: f.isNumeric(field, jsField)}
+		hasValue = true
+	}
+	return cells, names, hasValue
+}
+
+// add adds row to the table and renders the table using buff once it's full.
+func (t *tableBuffer) add(row map[string]tableCell, names []string, buff *bytes.Buffer, emit EmitFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, name := range names {
+		if !containsString(t.columns, name) {
+			t.columns = append(t.columns, name)
+		}
+	}
+	t.rows = append(t.rows, row)
+	if len(t.rows) >= t.size {
+		t.renderLocked(buff, emit)
+	}
+}
+
+// flush renders the remaining rows using buff.
+func (t *tableBuffer) flush(buff *bytes.Buffer, emit EmitFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.renderLocked(buff, emit)
+}
+
+// renderLocked emits the table line by line. Lines are emitted while locked so
+// tables of concurrent inputs are not interleaved. They have no parsed line, as a row
+// may be emitted long after its line.
+func (t *tableBuffer) renderLocked(buff *bytes.Buffer, emit EmitFunc) {
+	if len(t.rows) == 0 {
+		return
+	}
+	widths := make([]columnWidth, len(t.columns))
+	for idx, col := range t.columns {
+		widths[idx].width = displayWidth(col)
+		numeric, hasValue := true, false
+		for _, row := range t.rows {
+			cell, ok := row[col]
+			if w := displayWidth(cell.val); w > widths[idx].width {
+				widths[idx].width = w
+			}
+			if ok && !cell.missing {
+				numeric = numeric && cell.numeric
+				hasValue = true
+			}
+		}
+		widths[idx].right = numeric && hasValue
+	}
+	t.fitLocked(widths)
+
+	header := make(map[string]tableCell, len(t.columns))
+	bold := color.New(color.Bold)
+	for _, col := range t.columns {
+		header[col] = tableCell{val: bold.Sprint(col)}
+	}
+	line := func() {
+		if buff.Len() > 0 {
+			emit(buff.Bytes(), gjson.Result{})
+			buff.Reset()
+		}
+	}
+	buff.Reset()
+	t.writeBorder(buff, widths, "┌", "┬", "┐")
+	line()
+	t.writeRow(buff, widths, header)
+	line()
+	t.writeBorder(buff, widths, "├", "┼", "┤")
+	line()
+	for _, row := range t.rows {
+		t.writeRow(buff, widths, row)
+		line()
+	}
+	t.writeBorder(buff, widths, "└", "┴", "┘")
+	line()
+
+	t.columns = t.columns[:0]
+	t.rows = t.rows[:0]
+}
+
+func (t *tableBuffer) writeRow(buff *bytes.Buffer, widths []columnWidth, row map[string]tableCell) {
+	line := &bytes.Buffer{}
+	if t.borders {
+		line.WriteString("│ ")
+	}
+	for idx, col := range t.columns {
+		if idx > 0 {
+			if t.borders {
+				line.WriteString(" │ ")
+			} else {
+				line.WriteString("  ")
+			}
+		}
+		line.WriteString(widths[idx].pad(truncateDisplay(row[col].val, widths[idx].width)))
+	}
+	if t.borders {
+		line.WriteString(" │")
+	}
+	// No trailing spaces padding the last column
+	buff.WriteString(strings.TrimRight(line.String(), " "))
+	buff.WriteString("\n")
+}
+
+// fitLocked shrinks the widest columns until table lines fit in the max width,
+// down to minTableColumn cells. Values wider than their column are truncated.
+func (t *tableBuffer) fitLocked(widths []columnWidth) {
+	if t.maxWidth <= 0 || len(widths) == 0 {
+		return
+	}
+	total := 2 * (len(widths) - 1) // Column separators
+	if t.borders {
+		total = 3*(len(widths)-1) + 4
+	}
+	for _, w := range widths {
+		total += w.width
+	}
+	for total > t.maxWidth {
+		widest := 0
+		for idx, w := range widths {
+			if w.width > widths[widest].width {
+				widest = idx
+			}
+		}
+		if widths[widest].width <= minTableColumn {
+			return // Cannot fit, e.g. too many columns
+		}
+		widths[widest].width--
+		total--
+	}
+}
+
+func (t *tableBuffer) writeBorder(buff *bytes.Buffer, widths []columnWidth, left, middle, right string) {
+	if !t.borders {
+		return
+	}
+	buff.WriteString(left)
+	for idx, w := range widths {
+		if idx > 0 {
+			buff.WriteString(middle)
+		}
+		buff.WriteString(strings.Repeat("─", w.width+2))
+	}
+	buff.WriteString(right)
+	buff.WriteString("\n")
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
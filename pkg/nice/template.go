@@ -0,0 +1,152 @@
+package nice
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/tidwall/gjson"
+)
+
+// outTemplate formats lines by a Go text/template.
+// The template data is a map of the output fields (keyed by aliases, dot notation aliases
+// are nested), or of all fields of the line if no output field is set.
+type outTemplate struct {
+	tmpl *template.Template
+	refs [][]string // Field chains referenced by the template, e.g. .user.id
+}
+
+func newOutTemplate(text string) (*outTemplate, error) {
+	tmpl, err := template.New("line").Funcs(template.FuncMap{
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+	}).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &outTemplate{tmpl: tmpl}
+	walkTemplateFields(tmpl.Tree.Root, func(ident []string) {
+		t.refs = append(t.refs, ident)
+	})
+	return t, nil
+}
+
+// formatTemplate executes the output template on jsonLine and writes result to buff.
+// Fields referenced by the template but missing in the line are set to the missing placeholder.
+// Nothing is written if the line has none of the fields.
+func (f *Formatter) formatTemplate(jsonLine gjson.Result, buff *bytes.Buffer) {
+	var data map[string]interface{}
+	if len(f.fields) == 0 && f.exclude == nil && f.auto == nil {
+		// Non-object lines have no fields
+		data, _ = templateValue(jsonLine).(map[string]interface{})
+	} else {
+		data = make(map[string]interface{})
+		for _, field := range f.lineFields(jsonLine) {
+			val := f.value(field, field.get(jsonLine))
+			if strings.TrimSpace(val) == "" {
+				val = f.missing
+			}
+			setTemplateValue(data, strings.Split(field.alias, "."), val, true)
+		}
+	}
+	if len(data) == 0 {
+		return
+	}
+	for _, ref := range f.template.refs {
+		setTemplateValue(data, ref, f.missing, false)
+	}
+
+	if err := f.template.tmpl.Execute(buff, data); err != nil {
+		log.Printf("nice: failed to execute template: %v", err)
+		buff.Reset()
+	}
+}
+
+// templateValue converts a JSON value to template data.
+// Objects are converted to maps, arrays to slices and other values to printable strings.
+func templateValue(r gjson.Result) interface{} {
+	switch {
+	case r.IsObject():
+		m := make(map[string]interface{})
+		r.ForEach(func(key, val gjson.Result) bool {
+			m[key.Str] = templateValue(val)
+			return true
+		})
+		return m
+	case r.IsArray():
+		var arr []interface{}
+		r.ForEach(func(_, val gjson.Result) bool {
+			arr = append(arr, templateValue(val))
+			return true
+		})
+		return arr
+	default:
+		return FieldValue(r)
+	}
+}
+
+// setTemplateValue sets val to the nested keys of data. Existing values are only
+// overwritten if overwrite is true, keys under a non-object value are ignored.
+func setTemplateValue(data map[string]interface{}, keys []string, val string, overwrite bool) {
+	for _, key := range keys[:len(keys)-1] {
+		child, exists := data[key]
+		if !exists {
+			child = make(map[string]interface{})
+			data[key] = child
+		}
+		m, ok := child.(map[string]interface{})
+		if !ok {
+			return
+		}
+		data = m
+	}
+
+	last := keys[len(keys)-1]
+	if _, exists := data[last]; !exists || overwrite {
+		data[last] = val
+	}
+}
+
+// walkTemplateFields calls fn with the identifiers of every field node (e.g. .user.id)
+// in the template tree.
+func walkTemplateFields(node parse.Node, fn func(ident []string)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkTemplateFields(child, fn)
+		}
+	case *parse.ActionNode:
+		walkTemplateFields(n.Pipe, fn)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			walkTemplateFields(cmd, fn)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walkTemplateFields(arg, fn)
+		}
+	case *parse.FieldNode:
+		fn(n.Ident)
+	case *parse.IfNode:
+		walkTemplateFields(n.Pipe, fn)
+		walkTemplateFields(n.List, fn)
+		walkTemplateFields(n.ElseList, fn)
+	case *parse.RangeNode:
+		walkTemplateFields(n.Pipe, fn)
+		walkTemplateFields(n.List, fn)
+		walkTemplateFields(n.ElseList, fn)
+	case *parse.WithNode:
+		walkTemplateFields(n.Pipe, fn)
+		walkTemplateFields(n.List, fn)
+		walkTemplateFields(n.ElseList, fn)
+	}
+}
@@ -0,0 +1,55 @@
+package nice
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// timeLayouts are the layouts tried when parsing time from string values.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.UnixDate,
+}
+
+// ParseTime parses a JSON field as time.
+// Strings are parsed by common layouts or as number, numbers are treated as Unix epoch
+// in seconds, milliseconds, microseconds or nanoseconds depending on their magnitude.
+func ParseTime(jsField gjson.Result) (time.Time, bool) {
+	switch jsField.Type {
+	case gjson.Number:
+		return parseEpoch(jsField.Num), true
+	case gjson.String:
+		s := strings.TrimSpace(jsField.Str)
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, true
+			}
+		}
+		if num, err := strconv.ParseFloat(s, 64); err == nil {
+			return parseEpoch(num), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func parseEpoch(num float64) time.Time {
+	switch {
+	case num > 1e17: // Nanoseconds
+		return time.Unix(0, int64(num))
+	case num > 1e14: // Microseconds
+		return time.Unix(0, int64(num*1e3))
+	case num > 1e11: // Milliseconds
+		return time.Unix(0, int64(num*1e6))
+	default: // Seconds
+		return time.Unix(0, int64(num*1e9))
+	}
+}
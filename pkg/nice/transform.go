@@ -0,0 +1,60 @@
+package nice
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/pretty"
+)
+
+// transforms are the built-in transforms of output values, by name.
+var transforms = map[string]func(val string) string{
+	"upper":        strings.ToUpper,
+	"lower":        strings.ToLower,
+	"trim":         strings.TrimSpace,
+	"basename":     basename,
+	"json-compact": compactJSON,
+}
+
+// parseTransforms parses transforms in form of alias=name, separated by comma (,),
+// e.g. level=upper,path=basename. Transforms of the same alias are applied in order.
+func parseTransforms(spec string) (map[string][]func(string) string, error) {
+	fields := make(map[string][]func(string) string)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		idx := strings.LastIndex(part, "=")
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid transform %q, expecting alias=name", part)
+		}
+		alias, name := strings.TrimSpace(part[:idx]), strings.TrimSpace(part[idx+1:])
+		fn, ok := transforms[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown transform %q, expecting upper, lower, trim, basename or json-compact", name)
+		}
+		fields[alias] = append(fields[alias], fn)
+	}
+	return fields, nil
+}
+
+// basename returns the last element of path val, separated by slash or backslash.
+// Trailing separators are ignored.
+func basename(val string) string {
+	trimmed := strings.TrimRight(val, `/\`)
+	if trimmed == "" {
+		return val // Root or empty path
+	}
+	return trimmed[strings.LastIndexAny(trimmed, `/\`)+1:]
+}
+
+// compactJSON removes insignificant whitespace from val if it's JSON, e.g. a string
+// field holding an indented document. Other values are returned as is.
+func compactJSON(val string) string {
+	if !gjson.Valid(val) {
+		return val
+	}
+	return string(pretty.Ugly([]byte(val)))
+}
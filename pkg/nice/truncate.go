@@ -0,0 +1,52 @@
+package nice
+
+import "unicode/utf8"
+
+// truncateJSON truncates raw JSON to n characters, ended by … and the closing quote and
+// brackets of the strings, objects and arrays still open at the cut, so it still looks like JSON.
+// raw is returned as is if it's not longer than n characters.
+func truncateJSON(raw string, n int) string {
+	if utf8.RuneCountInString(raw) <= n {
+		return raw
+	}
+	var open []byte // Closing brackets of open objects and arrays
+	inString, escaped := false, false
+	cut, chars := 0, 0
+	for cut < len(raw) && chars < n {
+		c := raw[cut]
+		switch {
+		case inString && escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{':
+			open = append(open, '}')
+		case c == '[':
+			open = append(open, ']')
+		case c == '}', c == ']':
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+		}
+		_, size := utf8.DecodeRuneInString(raw[cut:])
+		cut += size
+		chars++
+	}
+
+	out := make([]byte, 0, cut+len("…")+len(open)+1)
+	out = append(out, raw[:cut]...)
+	if escaped { // Don't leave a dangling backslash escaping the closing quote
+		out = out[:len(out)-1]
+	}
+	out = append(out, "…"...)
+	if inString {
+		out = append(out, '"')
+	}
+	for idx := len(open) - 1; idx >= 0; idx-- {
+		out = append(out, open[idx])
+	}
+	return string(out)
+}
@@ -0,0 +1,195 @@
+package nice
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ellipsis is appended to truncated values.
+const ellipsis = "…"
+
+// maxWidths is the maximum number of runes of output values.
+type maxWidths struct {
+	all    int            // Applied to fields without their own width, 0 means unlimited
+	fields map[string]int // By field alias
+}
+
+// parseMaxWidths parses max widths in form of N or alias=N, separated by comma (,),
+// e.g. 120,msg=80 limits msg to 80 runes and other fields to 120 runes.
+func parseMaxWidths(spec string) (*maxWidths, error) {
+	w := &maxWidths{fields: make(map[string]int)}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, num := "", part
+		if idx := strings.LastIndex(part, "="); idx >= 0 {
+			name, num = strings.TrimSpace(part[:idx]), part[idx+1:]
+			if name == "" {
+				return nil, fmt.Errorf("invalid width %q, missing field", part)
+			}
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(num))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid width %q, expecting positive number", part)
+		}
+		if name == "" {
+			w.all = n
+		} else {
+			w.fields[name] = n
+		}
+	}
+	return w, nil
+}
+
+// truncate cuts val of field to its max width, ending with an ellipsis.
+// Width is counted by runes so multibyte characters are not cut.
+func (w *maxWidths) truncate(field outField, val string) string {
+	max, ok := w.fields[field.alias]
+	if !ok {
+		max = w.all
+	}
+	if max == 0 || utf8.RuneCountInString(val) <= max {
+		return val
+	}
+
+	runes := 0
+	for idx := range val {
+		if runes == max-1 {
+			return val[:idx] + ellipsis
+		}
+		runes++
+	}
+	return val
+}
+
+// columnWidth is the padded width of an output column.
+type columnWidth struct {
+	width int // 0 means unconstrained
+	right bool
+}
+
+// parseWidths parses column widths in form of N or >N (right-aligned), separated by comma (,).
+func parseWidths(spec string) ([]columnWidth, error) {
+	var widths []columnWidth
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		cw := columnWidth{right: strings.HasPrefix(part, ">")}
+		n, err := strconv.Atoi(strings.TrimPrefix(part, ">"))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid width %q, expecting N or >N", part)
+		}
+		cw.width = n
+		widths = append(widths, cw)
+	}
+	return widths, nil
+}
+
+// pad pads val with spaces to the column width. Values wider than the column are kept as is.
+func (cw columnWidth) pad(val string) string {
+	n := cw.width - displayWidth(val)
+	if n <= 0 {
+		return val
+	}
+	if cw.right {
+		return strings.Repeat(" ", n) + val
+	}
+	return val + strings.Repeat(" ", n)
+}
+
+// ansiRegex matches ANSI color escape sequences.
+var ansiRegex = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// displayWidth returns the number of terminal cells to display s.
+// Color escape sequences take no cell, East Asian wide characters take 2 cells.
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range ansiRegex.ReplaceAllString(s, "") {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// lineWidth returns the number of terminal cells to display line like displayWidth,
+// with tabs expanded to the next multiple of 8 cells.
+func lineWidth(line string) int {
+	width := 0
+	for _, r := range ansiRegex.ReplaceAllString(line, "") {
+		if r == '\t' {
+			width += 8 - width%8
+			continue
+		}
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// truncateDisplay cuts s to at most width terminal cells, ending with an ellipsis.
+// Color escape sequences are kept, and reset after the ellipsis if any.
+func truncateDisplay(s string, width int) string {
+	if displayWidth(s) <= width {
+		return s
+	}
+	var sb strings.Builder
+	cells, colored := 0, false
+	for idx := 0; idx < len(s); {
+		if s[idx] == '\x1b' {
+			if loc := ansiRegex.FindStringIndex(s[idx:]); loc != nil && loc[0] == 0 {
+				sb.WriteString(s[idx : idx+loc[1]])
+				idx += loc[1]
+				colored = true
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(s[idx:])
+		if cells+runeWidth(r) > width-1 { // Room for the ellipsis
+			break
+		}
+		cells += runeWidth(r)
+		sb.WriteString(s[idx : idx+size])
+		idx += size
+	}
+	sb.WriteString(ellipsis)
+	if colored {
+		sb.WriteString("\x1b[0m")
+	}
+	return sb.String()
+}
+
+// wideRanges are the common ranges of East Asian wide and fullwidth characters, and emojis.
+var wideRanges = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x1100, Hi: 0x115f, Stride: 1},
+		{Lo: 0x2e80, Hi: 0x303e, Stride: 1},
+		{Lo: 0x3041, Hi: 0x33ff, Stride: 1},
+		{Lo: 0x3400, Hi: 0x4dbf, Stride: 1},
+		{Lo: 0x4e00, Hi: 0x9fff, Stride: 1},
+		{Lo: 0xa000, Hi: 0xa4cf, Stride: 1},
+		{Lo: 0xac00, Hi: 0xd7a3, Stride: 1},
+		{Lo: 0xf900, Hi: 0xfaff, Stride: 1},
+		{Lo: 0xfe30, Hi: 0xfe4f, Stride: 1},
+		{Lo: 0xff00, Hi: 0xff60, Stride: 1},
+		{Lo: 0xffe0, Hi: 0xffe6, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1f300, Hi: 0x1f64f, Stride: 1},
+		{Lo: 0x1f900, Hi: 0x1f9ff, Stride: 1},
+		{Lo: 0x20000, Hi: 0x3fffd, Stride: 1},
+	},
+}
+
+func runeWidth(r rune) int {
+	switch {
+	case r == utf8.RuneError || unicode.Is(unicode.Mn, r) || unicode.IsControl(r):
+		return 0
+	case unicode.Is(wideRanges, r):
+		return 2
+	default:
+		return 1
+	}
+}
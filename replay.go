@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lnquy/nice/pkg/nice"
+)
+
+// replayer paces lines of each input by the gaps between their times, for --replay.
+// Each input has its own clock, except merged files which share one clock.
+type replayer struct {
+	timeField string
+	speed     float64         // Gaps are divided by this multiplier
+	done      <-chan struct{} // Interrupts sleeps when closed
+
+	mu     sync.Mutex
+	clocks map[*lineStats]*replayClock
+}
+
+// replayClock is the time of the last paced line of an input and when it was released.
+// It's only used by the goroutine printing the lines of its inputs.
+type replayClock struct {
+	timeField string
+	lineTime  time.Time
+	wallTime  time.Time
+}
+
+func newReplayer(timeField string, speed float64, done <-chan struct{}) *replayer {
+	return &replayer{timeField: timeField, speed: speed, done: done, clocks: make(map[*lineStats]*replayClock)}
+}
+
+// share paces the lines of sources by one clock of timeField, as they're printed
+// in order of that field by one goroutine when merged.
+func (r *replayer) share(sources []*lineStats, timeField string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clock := &replayClock{timeField: timeField}
+	for _, src := range sources {
+		r.clocks[src] = clock
+	}
+}
+
+// wait sleeps until line of input src is due, which is the time gap from the previous line
+// divided by the speed after the previous line was released.
+// Lines without time, or with time before the previous line, are due immediately.
+func (r *replayer) wait(p *printer, src *lineStats, line []byte) {
+	clock := r.clockOf(src)
+	jsonLine, _ := p.f.Parse(line)
+	t, ok := nice.ParseTime(jsonLine.Get(clock.timeField))
+	if !ok {
+		return
+	}
+	if !clock.lineTime.IsZero() && t.After(clock.lineTime) {
+		delay := time.Duration(float64(t.Sub(clock.lineTime))/r.speed) - time.Since(clock.wallTime)
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-r.done:
+				timer.Stop()
+			case <-timer.C:
+			}
+		}
+	}
+	if clock.lineTime.IsZero() || t.After(clock.lineTime) {
+		clock.lineTime = t
+	}
+	clock.wallTime = time.Now()
+}
+
+func (r *replayer) clockOf(src *lineStats) *replayClock {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clock, ok := r.clocks[src]
+	if !ok {
+		clock = &replayClock{timeField: r.timeField}
+		r.clocks[src] = clock
+	}
+	return clock
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// socketRetryInterval is the delay before reconnecting to a socket in follow mode.
+const socketRetryInterval = time.Second
+
+// isSocketURL reports whether the input entry is a tcp:// or unix:// socket URL.
+func isSocketURL(entry string) bool {
+	return strings.HasPrefix(entry, "tcp://") || strings.HasPrefix(entry, "unix://")
+}
+
+// scanSocket connects to the socket URL and calls fn on each received line.
+// In follow mode, it reconnects when the connection failed or dropped, until ctx cancelled.
+func scanSocket(ctx context.Context, url string, fn func(line []byte)) {
+	idx := strings.Index(url, "://")
+	network, addr := url[:idx], url[idx+3:]
+
+	dialer := net.Dialer{}
+	for {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err == nil {
+			logInfof("nice: [%v]: connected", url)
+			readSocket(ctx, url, conn, fn)
+		} else if ctx.Err() == nil {
+			log.Printf("nice: [%v]: failed to connect: %v", url, err)
+		}
+
+		if !fFollow || ctx.Err() != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(socketRetryInterval):
+			logInfof("nice: [%v]: reconnecting", url)
+		}
+	}
+}
+
+func readSocket(ctx context.Context, url string, conn net.Conn, fn func(line []byte)) {
+	// Unblock pending read when ctx cancelled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		if err := conn.Close(); err != nil && ctx.Err() == nil {
+			log.Printf("nice: [%v]: failed to close connection: %v", url, err)
+		}
+	}()
+
+	scanLines(ctx, url, conn, fn)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+
+	"github.com/lnquy/nice/pkg/nice"
+)
+
+// inputStats collects lines statistics of all inputs.
+var inputStats = &statsRegistry{}
+
+// lineStats counts lines of an input by their print result.
+// Counters are updated atomically as stdin may still be read while stats are printed.
+type lineStats struct {
+	name     string
+	read     uint64
+	printed  uint64
+	skipped  uint64
+	filtered uint64
+	invalid  uint64
+}
+
+func (s *lineStats) record(res nice.Result) {
+	atomic.AddUint64(&s.read, 1)
+	switch res {
+	case nice.Printed:
+		atomic.AddUint64(&s.printed, 1)
+	case nice.Skipped:
+		atomic.AddUint64(&s.skipped, 1)
+	case nice.Filtered:
+		atomic.AddUint64(&s.filtered, 1)
+	case nice.Invalid:
+		atomic.AddUint64(&s.invalid, 1)
+	case nice.Passthrough:
+		atomic.AddUint64(&s.printed, 1)
+		atomic.AddUint64(&s.invalid, 1)
+	}
+}
+
+// statsRegistry holds stats of inputs in order of registering.
+type statsRegistry struct {
+	mu    sync.Mutex
+	stats []*lineStats
+}
+
+// add registers and returns new stats of the input name.
+func (r *statsRegistry) add(name string) *lineStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := &lineStats{name: name}
+	r.stats = append(r.stats, s)
+	return s
+}
+
+// print writes stats of each input and the total as a table to w.
+func (r *statsRegistry) print(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "input\tread\tprinted\tskipped\tfiltered\tinvalid")
+	total := lineStats{name: "total"}
+	for _, s := range r.stats {
+		c := s.snapshot()
+		total.read += c.read
+		total.printed += c.printed
+		total.skipped += c.skipped
+		total.filtered += c.filtered
+		total.invalid += c.invalid
+		c.writeRow(tw)
+	}
+	if len(r.stats) > 1 {
+		total.writeRow(tw)
+	}
+	_ = tw.Flush()
+}
+
+func (s *lineStats) snapshot() lineStats {
+	return lineStats{
+		name:     s.name,
+		read:     atomic.LoadUint64(&s.read),
+		printed:  atomic.LoadUint64(&s.printed),
+		skipped:  atomic.LoadUint64(&s.skipped),
+		filtered: atomic.LoadUint64(&s.filtered),
+		invalid:  atomic.LoadUint64(&s.invalid),
+	}
+}
+
+func (s *lineStats) writeRow(w io.Writer) {
+	fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\n", s.name, s.read, s.printed, s.skipped, s.filtered, s.invalid)
+}
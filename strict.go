@@ -0,0 +1,32 @@
+package main
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+// strictExitCode is the exit code when any line failed parsing in strict mode.
+const strictExitCode = 2
+
+// parseChecker records lines which cannot be parsed by the input format, for --strict.
+type parseChecker struct {
+	failFast bool   // Stop all inputs at the first invalid line
+	invalid  uint64 // Number of invalid lines, updated atomically
+}
+
+// check records line at srcLine of input name if it cannot be parsed.
+func (c *parseChecker) check(p *printer, name string, srcLine uint64, line []byte) {
+	if _, valid := p.f.Parse(line); valid {
+		return
+	}
+	atomic.AddUint64(&c.invalid, 1)
+	log.Printf("nice: [%v:%d]: invalid line", name, srcLine)
+	if c.failFast {
+		p.stop()
+	}
+}
+
+// failed reports whether any line failed parsing.
+func (c *parseChecker) failed() bool {
+	return atomic.LoadUint64(&c.invalid) > 0
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// tailChunkSize is the size of chunks read backwards when looking for the last lines of a file.
+const tailChunkSize = 64 * 1024
+
+// seekTail moves the offset of f to the beginning of its last n lines,
+// by reading the file backwards in chunks, and returns the new offset.
+func seekTail(f *os.File, n int) (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := fi.Size()
+
+	buf := make([]byte, tailChunkSize)
+	offset := size
+	lines := 0
+	for offset > 0 {
+		readSize := int64(tailChunkSize)
+		if offset < readSize {
+			readSize = offset
+		}
+		offset -= readSize
+		if _, err := f.ReadAt(buf[:readSize], offset); err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		for i := readSize - 1; i >= 0; i-- {
+			// Newline at the end of file doesn't start a new line
+			if buf[i] != '\n' || offset+i == size-1 {
+				continue
+			}
+			lines++
+			if lines == n {
+				return f.Seek(offset+i+1, io.SeekStart)
+			}
+		}
+	}
+
+	// File has less than n lines
+	return f.Seek(0, io.SeekStart)
+}
+
+// lineRing keeps the last n lines added.
+type lineRing struct {
+	lines [][]byte
+	next  int
+	full  bool
+}
+
+func newLineRing(n int) *lineRing {
+	return &lineRing{lines: make([][]byte, n)}
+}
+
+func (r *lineRing) add(line []byte) {
+	// Reuse the buffer of the overwritten line
+	r.lines[r.next] = append(r.lines[r.next][:0], line...)
+	r.next++
+	if r.next == len(r.lines) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// flush calls fn on the kept lines, from oldest to newest.
+func (r *lineRing) flush(fn func(line []byte)) {
+	if r.full {
+		for _, line := range r.lines[r.next:] {
+			fn(line)
+		}
+	}
+	for _, line := range r.lines[:r.next] {
+		fn(line)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package main
+
+import (
+	"os"
+	"strconv"
+)
+
+// terminalWidth returns the number of columns of terminal f, as advertised by COLUMNS.
+func terminalWidth(f *os.File) (int, bool) {
+	cols, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	return cols, err == nil && cols > 0
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/lnquy/nice/pkg/nice"
+	"github.com/tidwall/gjson"
+)
+
+// uniqFilter keeps only one line per value of a field across all inputs: the first one,
+// or the last one if keepLast, which are held until flushed at the end.
+// Lines without the field are always kept.
+// Keys seen are tracked in memory, up to max keys if set: the oldest key is forgotten
+// (keep first) or its line is written (keep last) when there are more.
+type uniqFilter struct {
+	field    string
+	keepLast bool
+	max      int
+
+	mu    sync.Mutex
+	lines map[string]*uniqLine // Line of each key seen, only held if keepLast
+	order []string             // Keys in order of first occurrence
+}
+
+// uniqLine is a line held in keep last mode, emitted as is once flushed.
+type uniqLine struct {
+	raw    string // Parsed line, copied as the input buffer is reused
+	line   []byte // Formatted line
+	prefix []byte // Line number prefix
+}
+
+func newUniqFilter(field string, keepLast bool, max int) *uniqFilter {
+	return &uniqFilter{field: field, keepLast: keepLast, max: max, lines: make(map[string]*uniqLine)}
+}
+
+// key returns the uniq key of jsonLine and reports whether it has the field.
+func (u *uniqFilter) key(jsonLine gjson.Result) (string, bool) {
+	key := nice.FieldValue(jsonLine.Get(u.field))
+	return key, key != ""
+}
+
+// first reports whether key is seen for the first time, in keep first mode.
+func (u *uniqFilter) first(key string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, ok := u.lines[key]; ok {
+		return false
+	}
+	u.lines[key] = nil
+	u.order = append(u.order, key)
+	if u.max > 0 && len(u.order) > u.max {
+		delete(u.lines, u.order[0])
+		u.order = u.order[1:]
+	}
+	return true
+}
+
+// hold holds the formatted line of jsonLine as the last line of key in keep last mode.
+// If there are more keys than max, the line of the oldest key is emitted by emit.
+func (u *uniqFilter) hold(key string, jsonLine gjson.Result, line, prefix []byte, emit func(jsonLine gjson.Result, line, prefix []byte)) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	held, ok := u.lines[key]
+	if !ok {
+		held = &uniqLine{}
+		u.lines[key] = held
+		u.order = append(u.order, key)
+	}
+	held.raw = string(append([]byte(nil), jsonLine.Raw...))
+	held.line = append(held.line[:0], line...)
+	held.prefix = append(held.prefix[:0], prefix...)
+	if u.max > 0 && len(u.order) > u.max {
+		u.emitLocked(u.order[0], emit)
+		delete(u.lines, u.order[0])
+		u.order = u.order[1:]
+	}
+}
+
+// flush emits the held lines by emit, in order of their keys first seen.
+func (u *uniqFilter) flush(emit func(jsonLine gjson.Result, line, prefix []byte)) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.keepLast {
+		return
+	}
+	for _, key := range u.order {
+		u.emitLocked(key, emit)
+	}
+	u.lines = make(map[string]*uniqLine)
+	u.order = nil
+}
+
+func (u *uniqFilter) emitLocked(key string, emit func(jsonLine gjson.Result, line, prefix []byte)) {
+	held := u.lines[key]
+	emit(gjson.Parse(held.raw), held.line, held.prefix)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// watchPollInterval is the delay between checks for created or removed files in the watched directory.
+const watchPollInterval = time.Second
+
+// watchDir follows all files in dir matching pattern, including files created later,
+// until ctx cancelled. Reading a file is stopped once it's removed.
+// Polls the directory so it works the same on every platform and on network filesystems,
+// files are picked up or released up to watchPollInterval after they're created or removed.
+func watchDir(ctx context.Context, wg *sync.WaitGroup, dir, pattern string, p *printer, out io.Writer) {
+	defer wg.Done()
+
+	fileWg := sync.WaitGroup{}
+	defer fileWg.Wait() // File contexts are children of ctx so they're all cancelled here
+	watched := make(map[string]context.CancelFunc)
+	skipped := make(map[string]bool) // Files beyond --max-open, logged once
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+	for {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			log.Printf("nice: [%v]: failed to list files: %v. Exit", dir, err)
+			return
+		}
+		current := make(map[string]bool, len(matches))
+		for _, path := range matches {
+			if fi, err := os.Stat(path); err != nil || fi.IsDir() {
+				continue
+			}
+			current[path] = true
+			if _, ok := watched[path]; ok {
+				continue
+			}
+			if len(watched) >= fMaxOpen {
+				if !skipped[path] {
+					log.Printf("nice: [%v]: %d files followed already (--max-open). Skip file", path, fMaxOpen)
+					skipped[path] = true
+				}
+				continue
+			}
+			delete(skipped, path)
+			logInfof("nice: [%v]: start watching file", path)
+			fileCtx, cancel := context.WithCancel(ctx)
+			watched[path] = cancel
+			fileWg.Add(1)
+			go pipeFile(fileCtx, &fileWg, path, p, out)
+		}
+		for path := range skipped {
+			if !current[path] {
+				delete(skipped, path)
+			}
+		}
+		for path, cancel := range watched {
+			if !current[path] {
+				logInfof("nice: [%v]: file removed. Stop watching", path)
+				cancel()
+				delete(watched, path)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// syncWriter serializes writes to the underlying writer, so lines written
+// concurrently by multiple inputs are not interleaved.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newSyncWriter(w io.Writer) *syncWriter {
+	return &syncWriter{w: w}
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// bufferedWriter buffers writes to the underlying writer and flushes them periodically,
+// so lines appear promptly on low-volume streams while high-volume streams are batched.
+// If the flush interval is 0, the buffer is only flushed when it's full or closed.
+// It's safe for concurrent use.
+type bufferedWriter struct {
+	mu    sync.Mutex
+	w     *bufio.Writer
+	onErr func(error) // Called with errors of periodic flushes
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func newBufferedWriter(w io.Writer, flushInterval time.Duration, onErr func(error)) *bufferedWriter {
+	bw := &bufferedWriter{
+		w:     bufio.NewWriterSize(w, 64*1024),
+		onErr: onErr,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if flushInterval > 0 {
+		go bw.flushLoop(flushInterval)
+	} else {
+		close(bw.done)
+	}
+	return bw
+}
+
+func (w *bufferedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+func (w *bufferedWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Flush()
+}
+
+// Close stops the periodic flushing and flushes the remaining buffered data.
+// It doesn't close the underlying writer.
+func (w *bufferedWriter) Close() error {
+	close(w.stop)
+	<-w.done
+	return w.Flush()
+}
+
+func (w *bufferedWriter) flushLoop(interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			if err := w.Flush(); err != nil {
+				w.onErr(err)
+			}
+		}
+	}
+}
+
+// isBrokenPipe reports whether err is caused by writing to a closed pipe or socket,
+// e.g. the downstream consumer (nice ... | head) exited.
+func isBrokenPipe(err error) bool {
+	switch e := err.(type) {
+	case *os.PathError:
+		err = e.Err
+	case *net.OpError:
+		err = e.Err
+		if se, ok := err.(*os.SyscallError); ok {
+			err = se.Err
+		}
+	case *os.SyscallError:
+		err = e.Err
+	}
+	return err == syscall.EPIPE
+}